@@ -0,0 +1,298 @@
+package goreq
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ctxSkipAuthType struct{}
+
+// ctxSkipAuth marks requests sent by auth middlewares themselves, like token requests.
+var ctxSkipAuth = &ctxSkipAuthType{}
+
+type ctxAuthorizedType struct{}
+
+// ctxAuthorized marks requests whose Authorization header is set by a middleware.
+var ctxAuthorized = &ctxAuthorizedType{}
+
+// hasUserAuth reports whether the Authorization header of req is set by user.
+func hasUserAuth(req *Request) bool {
+	return req.Header.Get("Authorization") != "" && req.Context().Value(ctxAuthorized) == nil
+}
+
+// canReplay reports whether req can be sent again, that is its body can be reset by GetBody.
+func canReplay(req *Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// SetBearerToken sets the Authorization header to "Bearer token".
+func (s *Request) SetBearerToken(token string) *Request {
+	s.Header.Set("Authorization", "Bearer "+token)
+	return s
+}
+
+// WithBearerToken sends requests with a static bearer token. Requests already have
+// Authorization are not changed.
+func WithBearerToken(token string) Middleware {
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if req.Context().Value(ctxSkipAuth) == nil && req.Header.Get("Authorization") == "" {
+				req.SetBearerToken(token)
+			}
+			return h(req)
+		}
+	}
+}
+
+// Token is an OAuth2 token.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// valid reports whether the token can be used for delta at least.
+func (t *Token) valid(delta time.Duration) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Until(t.Expiry) > delta)
+}
+
+// Valid reports whether the token is not expired.
+func (t *Token) Valid() bool {
+	return t.valid(0)
+}
+
+func (t *Token) header() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// TokenStore keeps the token of WithOAuth2, so that it can be shared or persisted.
+type TokenStore interface {
+	// Token returns the stored token, or nil if there's none.
+	Token() (*Token, error)
+	SetToken(t *Token) error
+}
+
+// MemoryTokenStore keeps the token in memory, it's the default TokenStore.
+type MemoryTokenStore struct {
+	lock  sync.Mutex
+	token *Token
+}
+
+func (s *MemoryTokenStore) Token() (*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.token, nil
+}
+
+func (s *MemoryTokenStore) SetToken(t *Token) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.token = t
+	return nil
+}
+
+// OAuth2Error is the error response of the token endpoint.
+type OAuth2Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuth2Error) Error() string {
+	msg := fmt.Sprintf("oauth2: token request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += " " + e.Description
+	}
+	return msg
+}
+
+type OAuth2Opinion struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken gets tokens by the refresh token grant. Without it, tokens are got by
+	// the client credentials grant, and refreshed if the endpoint returns a refresh token.
+	RefreshToken string
+	// AuthInParams sends client id and secret in the request body instead of basic auth.
+	AuthInParams bool
+	// EndpointParams are extra parameters sent to the token endpoint.
+	EndpointParams map[string]string
+	// Store keeps the token, a MemoryTokenStore by default.
+	Store TokenStore
+	// ExpiryDelta refreshes tokens this long before they expire, 10 seconds by default.
+	ExpiryDelta time.Duration
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+type oauth2Source struct {
+	opt  OAuth2Opinion
+	lock sync.Mutex
+	call *tokenCall
+}
+
+// token returns a valid token. If stale is not nil, it's rejected by server and a new
+// token is fetched unless the stored one is already different. Concurrent calls share
+// one request to the token endpoint.
+func (s *oauth2Source) token(c *Client, stale *Token) (*Token, error) {
+	s.lock.Lock()
+	if call := s.call; call != nil {
+		s.lock.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+	t, err := s.opt.Store.Token()
+	if err != nil {
+		s.lock.Unlock()
+		return nil, err
+	}
+	if t.valid(s.opt.ExpiryDelta) && (stale == nil || t.AccessToken != stale.AccessToken) {
+		s.lock.Unlock()
+		return t, nil
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	s.call = call
+	s.lock.Unlock()
+
+	call.token, call.err = s.fetch(c, t)
+	if call.err == nil {
+		call.err = s.opt.Store.SetToken(call.token)
+	}
+	s.lock.Lock()
+	s.call = nil
+	s.lock.Unlock()
+	close(call.done)
+	return call.token, call.err
+}
+
+func (s *oauth2Source) fetch(c *Client, old *Token) (*Token, error) {
+	refresh := s.opt.RefreshToken
+	if old != nil && old.RefreshToken != "" {
+		refresh = old.RefreshToken
+	}
+	if refresh != "" {
+		t, err := s.request(c, map[string]string{"grant_type": "refresh_token", "refresh_token": refresh})
+		if err == nil || s.opt.RefreshToken != "" {
+			if t != nil && t.RefreshToken == "" {
+				t.RefreshToken = refresh
+			}
+			return t, err
+		}
+	}
+	params := map[string]string{"grant_type": "client_credentials"}
+	if len(s.opt.Scopes) > 0 {
+		params["scope"] = strings.Join(s.opt.Scopes, " ")
+	}
+	return s.request(c, params)
+}
+
+func (s *oauth2Source) request(c *Client, params map[string]string) (*Token, error) {
+	for k, v := range s.opt.EndpointParams {
+		params[k] = v
+	}
+	if s.opt.AuthInParams {
+		params["client_id"] = s.opt.ClientID
+		if s.opt.ClientSecret != "" {
+			params["client_secret"] = s.opt.ClientSecret
+		}
+	}
+	req := Post(s.opt.TokenURL).SetFormBody(params).AddHeader("Accept", "application/json").
+		NoCache().SetClient(c)
+	if !s.opt.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(s.opt.ClientID), url.QueryEscape(s.opt.ClientSecret))
+	}
+	resp := req.addContextValue(ctxSkipAuth, struct{}{}).Do()
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	var body struct {
+		OAuth2Error
+		Token
+		ExpiresIn json.Number `json:"expires_in"`
+	}
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if ct == "application/x-www-form-urlencoded" || ct == "text/plain" {
+		v, err := url.ParseQuery(string(resp.Body))
+		if err != nil {
+			return nil, err
+		}
+		body.Code, body.Description = v.Get("error"), v.Get("error_description")
+		body.AccessToken, body.TokenType, body.RefreshToken = v.Get("access_token"), v.Get("token_type"), v.Get("refresh_token")
+		body.ExpiresIn = json.Number(v.Get("expires_in"))
+	} else if err := json.Unmarshal(resp.Body, &body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("oauth2: cannot parse token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Code != "" || body.AccessToken == "" {
+		body.OAuth2Error.StatusCode = resp.StatusCode
+		return nil, &body.OAuth2Error
+	}
+	t := body.Token
+	if sec, err := strconv.ParseInt(string(body.ExpiresIn), 10, 64); err == nil && sec > 0 {
+		t.Expiry = time.Now().Add(time.Duration(sec) * time.Second)
+	}
+	return &t, nil
+}
+
+// WithOAuth2 sends requests with OAuth2 tokens got from opt.TokenURL through the client.
+// Tokens are refreshed when they expire, or when a request gets 401 Unauthorized, which
+// is then sent again if its body can be reset. Requests already have Authorization are
+// not changed.
+func WithOAuth2(opt *OAuth2Opinion) Middleware {
+	src := &oauth2Source{opt: *opt}
+	if src.opt.Store == nil {
+		src.opt.Store = &MemoryTokenStore{}
+	}
+	if src.opt.ExpiryDelta == 0 {
+		src.opt.ExpiryDelta = 10 * time.Second
+	}
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if req.Context().Value(ctxSkipAuth) != nil || hasUserAuth(req) {
+				return h(req)
+			}
+			t, err := src.token(x, nil)
+			if err != nil {
+				return &Response{Req: req, Err: err}
+			}
+			req.addContextValue(ctxAuthorized, struct{}{}).Header.Set("Authorization", t.header())
+			res := h(req)
+			if res == nil || res.Response == nil || res.StatusCode != http.StatusUnauthorized || !canReplay(req) {
+				return res
+			}
+			if req.Debug {
+				log.Println("token is rejected, refresh it and send", req.URL, "again")
+			}
+			if t, err = src.token(x, t); err != nil {
+				return res
+			}
+			r, err := req.clone(req.Context())
+			if err != nil {
+				return res
+			}
+			r.Header.Set("Authorization", t.header())
+			return h(r)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithBearerToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+	c := NewClient(WithBearerToken("abc"))
+	txt, _ := Get(ts.URL).SetClient(c).Do().Txt()
+	assert.Equal(t, "Bearer abc", txt)
+	txt, _ = Get(ts.URL).SetBearerToken("own").SetClient(c).Do().Txt()
+	assert.Equal(t, "Bearer own", txt)
+}
+
+// oauth2Server issues tokens numbered from 1, and accepts only the latest token.
+type oauth2Server struct {
+	*httptest.Server
+	calls   int32
+	current int32
+	grants  []string
+	lock    sync.Mutex
+	expires int
+}
+
+func newOAuth2Server(t *testing.T) *oauth2Server {
+	s := &oauth2Server{expires: 3600}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			user, pass, _ := r.BasicAuth()
+			if user != "id" || pass != "secret" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+				return
+			}
+			_ = r.ParseForm()
+			s.lock.Lock()
+			s.grants = append(s.grants, r.PostForm.Get("grant_type")+":"+r.PostForm.Get("refresh_token")+r.PostForm.Get("scope"))
+			s.lock.Unlock()
+			atomic.AddInt32(&s.calls, 1)
+			time.Sleep(20 * time.Millisecond)
+			n := atomic.AddInt32(&s.current, 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":%d,"refresh_token":"r%d"}`, n, s.expires, n)
+		default:
+			if r.Header.Get("Authorization") != fmt.Sprintf("Bearer t%d", atomic.LoadInt32(&s.current)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			_, _ = fmt.Fprint(w, r.Header.Get("Authorization"), string(b))
+		}
+	}))
+	return s
+}
+
+func (s *oauth2Server) Grants() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.grants...)
+}
+
+func TestWithOAuth2(t *testing.T) {
+	s := newOAuth2Server(t)
+	defer s.Close()
+	c := NewClient()
+	c.Use(WithOAuth2(&OAuth2Opinion{
+		TokenURL:     s.URL + "/token",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"a", "b"},
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txt, err := Get(s.URL).SetClient(c).Do().Txt()
+			assert.NoError(t, err)
+			assert.Equal(t, "Bearer t1", txt)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.calls))
+
+	// the token is revoked by server, requests get 401 and are sent again with a new token
+	atomic.AddInt32(&s.current, 1)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txt, err := Post(s.URL).SetRawBody([]byte("body")).SetClient(c).Do().Txt()
+			assert.NoError(t, err)
+			assert.Equal(t, "Bearer t3body", txt)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"client_credentials:a b", "refresh_token:r1"}, s.Grants())
+
+	txt, _ := Get(s.URL).SetBearerToken("t3").SetClient(c).Do().Txt()
+	assert.Equal(t, "Bearer t3", txt)
+}
+
+func TestWithOAuth2Expiry(t *testing.T) {
+	s := newOAuth2Server(t)
+	defer s.Close()
+	s.expires = 1
+	store := &MemoryTokenStore{}
+	c := NewClient(WithOAuth2(&OAuth2Opinion{
+		TokenURL:     s.URL + "/token",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RefreshToken: "initial",
+		Store:        store,
+		ExpiryDelta:  time.Millisecond,
+	}))
+	txt, _ := Get(s.URL).SetClient(c).Do().Txt()
+	assert.Equal(t, "Bearer t1", txt)
+	tok, _ := store.Token()
+	assert.True(t, tok.Valid())
+	assert.Equal(t, "r1", tok.RefreshToken)
+
+	time.Sleep(1100 * time.Millisecond)
+	assert.False(t, tok.Valid())
+	txt, _ = Get(s.URL).SetClient(c).Do().Txt()
+	assert.Equal(t, "Bearer t2", txt)
+	assert.Equal(t, []string{"refresh_token:initial", "refresh_token:r1"}, s.Grants())
+
+	c = NewClient(WithOAuth2(&OAuth2Opinion{TokenURL: s.URL + "/token", ClientID: "id", ClientSecret: "wrong"}))
+	err := Get(s.URL).SetClient(c).Do().Err
+	if assert.IsType(t, &OAuth2Error{}, err) {
+		assert.Equal(t, "invalid_client", err.(*OAuth2Error).Code)
+		assert.Equal(t, http.StatusUnauthorized, err.(*OAuth2Error).StatusCode)
+	}
+}
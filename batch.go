@@ -0,0 +1,165 @@
+package goreq
+
+import (
+	"context"
+	"sync"
+)
+
+type BatchMode uint8
+
+const (
+	// CollectAll sends every request whatever others fail.
+	CollectAll BatchMode = iota
+	// FailFast cancels the rest of requests once one fails.
+	FailFast
+)
+
+type BatchOpinion struct {
+	// Workers is how many requests are sent at the same time, 10 by default.
+	Workers int
+	Mode    BatchMode
+	// Context cancels the requests not finished yet when it's done.
+	Context context.Context
+	// IsRespOk tells whether a response failed in FailFast mode. By default a response
+	// with error fails.
+	IsRespOk func(*Response) bool
+}
+
+// BatchResult is the response of the request at Index of a Batch.
+type BatchResult struct {
+	Index int
+	Resp  *Response
+}
+
+// Batch sends requests by workers of a Client. Requests pass every middleware of the
+// client, so limiters still work.
+type Batch struct {
+	opt     BatchOpinion
+	client  *Client
+	reqs    []*Request
+	resps   []*Response
+	results chan BatchResult
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	lock sync.Mutex
+	err  *Response
+}
+
+// Batch starts sending reqs and returns at once.
+func (s *Client) Batch(reqs []*Request, opt *BatchOpinion) *Batch {
+	b := &Batch{
+		client:  s,
+		reqs:    reqs,
+		resps:   make([]*Response, len(reqs)),
+		results: make(chan BatchResult, len(reqs)),
+		done:    make(chan struct{}),
+	}
+	if opt != nil {
+		b.opt = *opt
+	}
+	if b.opt.Workers <= 0 {
+		b.opt.Workers = 10
+	}
+	if b.opt.Context == nil {
+		b.opt.Context = context.Background()
+	}
+	b.ctx, b.cancel = context.WithCancel(b.opt.Context)
+	go b.run()
+	return b
+}
+
+// DoAll sends reqs and returns their responses in the same order.
+func (s *Client) DoAll(reqs []*Request, opt *BatchOpinion) []*Response {
+	return s.Batch(reqs, opt).Wait()
+}
+
+func (b *Batch) run() {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(b.opt.Workers)
+	for i := 0; i < b.opt.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				b.results <- BatchResult{Index: i, Resp: b.do(i)}
+			}
+		}()
+	}
+	for i := range b.reqs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	b.cancel()
+	close(b.results)
+	close(b.done)
+}
+
+func (b *Batch) do(i int) *Response {
+	req := b.reqs[i]
+	var res *Response
+	if err := b.ctx.Err(); err != nil {
+		res = &Response{Req: req, Err: err}
+	} else if isStream(req) {
+		// the body of a streaming response is read after the batch, so it's never canceled
+		res = req.callback(b.client.Do(req))
+	} else {
+		// a clone is sent, so the context of batch doesn't stay in the request of caller
+		ctx, cancel := context.WithCancel(req.Context())
+		stop := context.AfterFunc(b.ctx, cancel)
+		if r, err := req.clone(ctx); err != nil {
+			res = &Response{Req: req, Err: err}
+		} else {
+			res = b.client.Do(r)
+			// the response is of the request of caller, as if it's sent by Request.Do
+			res.Req = req
+			res = req.callback(res)
+		}
+		stop()
+		cancel()
+	}
+	b.resps[i] = res
+	if b.opt.Mode == FailFast && b.failed(res) {
+		b.lock.Lock()
+		if b.err == nil {
+			b.err = res
+		}
+		b.lock.Unlock()
+		b.cancel()
+	}
+	return res
+}
+
+func (b *Batch) failed(res *Response) bool {
+	if res == nil || res.Err != nil {
+		return true
+	}
+	return b.opt.IsRespOk != nil && !b.opt.IsRespOk(res)
+}
+
+// Results returns a channel receiving results in the order requests finish. It's closed
+// when all requests finish.
+func (b *Batch) Results() <-chan BatchResult {
+	return b.results
+}
+
+// Wait waits for all requests and returns their responses in the order of requests.
+// Requests not sent because of cancellation get a response with the error of context.
+func (b *Batch) Wait() []*Response {
+	<-b.done
+	return b.resps
+}
+
+// Cancel cancels requests not finished yet.
+func (b *Batch) Cancel() {
+	b.cancel()
+}
+
+// Failed returns the response which failed a FailFast batch first, or nil.
+func (b *Batch) Failed() *Response {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.err
+}
@@ -0,0 +1,138 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sleepServer sleeps the milliseconds in query d and answers the query v.
+func sleepServer(working, maxWorking *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(working, 1)
+		defer atomic.AddInt32(working, -1)
+		for {
+			m := atomic.LoadInt32(maxWorking)
+			if n <= m || atomic.CompareAndSwapInt32(maxWorking, m, n) {
+				break
+			}
+		}
+		d, _ := strconv.Atoi(r.URL.Query().Get("d"))
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Duration(d) * time.Millisecond):
+		}
+		if r.URL.Query().Get("v") == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = fmt.Fprint(w, r.URL.Query().Get("v"))
+	}))
+}
+
+func TestClient_DoAll(t *testing.T) {
+	var working, maxWorking int32
+	ts := sleepServer(&working, &maxWorking)
+	defer ts.Close()
+
+	var reqs []*Request
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, Get(fmt.Sprintf("%s?d=%d&v=%d", ts.URL, (10-i)*5, i)))
+	}
+	resps := NewClient().DoAll(reqs, &BatchOpinion{Workers: 3})
+	assert.Len(t, resps, 10)
+	for i, resp := range resps {
+		txt, err := resp.Txt()
+		assert.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(i), txt)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&maxWorking))
+
+	// requests are not tied to the finished batch and can be sent again
+	txt, err := reqs[0].Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "0", txt)
+
+	// limiters of the client work for batches too
+	atomic.StoreInt32(&maxWorking, 0)
+	c := NewClient(WithParallelismLimiter(false, &ParallelismLimiterOpinion{
+		LimiterMatcher: LimiterMatcher{Glob: "*"},
+		Parallelism:    2,
+	}))
+	reqs = reqs[:0]
+	for i := 0; i < 6; i++ {
+		reqs = append(reqs, Get(fmt.Sprintf("%s?d=10&v=%d", ts.URL, i)))
+	}
+	for _, resp := range c.DoAll(reqs, nil) {
+		assert.NoError(t, resp.Err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxWorking))
+}
+
+func TestBatch_FailFast(t *testing.T) {
+	var working, maxWorking int32
+	ts := sleepServer(&working, &maxWorking)
+	defer ts.Close()
+
+	reqs := []*Request{
+		Get(ts.URL + "?d=10&v=fail"),
+		Get(ts.URL + "?d=1000&v=slow"),
+		Get(ts.URL + "?d=0&v=late"),
+	}
+	b := NewClient().Batch(reqs, &BatchOpinion{
+		Workers:  2,
+		Mode:     FailFast,
+		IsRespOk: func(resp *Response) bool { return resp.StatusCode == http.StatusOK },
+	})
+	start := time.Now()
+	resps := b.Wait()
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.Equal(t, http.StatusInternalServerError, resps[0].StatusCode)
+	assert.True(t, errors.Is(resps[1].Err, context.Canceled))
+	assert.True(t, errors.Is(resps[2].Err, context.Canceled))
+	assert.Equal(t, resps[0], b.Failed())
+	for i, resp := range resps {
+		assert.Same(t, reqs[i], resp.Req)
+	}
+
+	// all requests are sent in CollectAll mode
+	resps = NewClient().DoAll([]*Request{Get(ts.URL + "?v=fail"), Get(ts.URL + "?v=ok")}, nil)
+	assert.Equal(t, http.StatusInternalServerError, resps[0].StatusCode)
+	txt, err := resps[1].Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", txt)
+}
+
+func TestBatch_Results(t *testing.T) {
+	var working, maxWorking int32
+	ts := sleepServer(&working, &maxWorking)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reqs := []*Request{
+		Get(ts.URL + "?d=100&v=0"),
+		Get(ts.URL + "?d=0&v=1"),
+		Get(ts.URL + "?d=1000&v=2"),
+	}
+	b := NewClient().Batch(reqs, &BatchOpinion{Context: ctx})
+	var order []int
+	for r := range b.Results() {
+		order = append(order, r.Index)
+		if r.Index == 0 {
+			cancel()
+		}
+	}
+	assert.Equal(t, []int{1, 0, 2}, order)
+	resps := b.Wait()
+	assert.NoError(t, resps[0].Err)
+	assert.True(t, errors.Is(resps[2].Err, context.Canceled))
+	assert.Nil(t, b.Failed())
+}
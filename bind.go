@@ -0,0 +1,306 @@
+package goreq
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldRequiredErr is returned when a required field matches nothing.
+var FieldRequiredErr = errors.New("required field is missing")
+
+// BindError reports which field failed to bind.
+type BindError struct {
+	Field string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("bind %s: %s", e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// bindTag is a parsed `goreq` struct tag, like `goreq:"css=a.title;attr=href;required"`.
+type bindTag struct {
+	sel      string
+	attr     string
+	html     bool
+	required bool
+	layout   string
+}
+
+func parseBindTag(key, tag string) (bindTag, error) {
+	var t bindTag
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			k, v = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		switch k {
+		case key:
+			t.sel = v
+		case "attr":
+			t.attr = v
+		case "html":
+			t.html = true
+		case "text":
+		case "required":
+			t.required = true
+		case "layout":
+			t.layout = v
+		default:
+			return t, fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return t, nil
+}
+
+// bindNode is an element of a document the binder can search in.
+type bindNode interface {
+	find(sel string) ([]bindNode, error)
+	text() string
+	attr(name string) (string, bool)
+	html() (string, error)
+}
+
+type cssNode struct {
+	*goquery.Selection
+}
+
+func (n cssNode) find(sel string) ([]bindNode, error) {
+	var nodes []bindNode
+	n.Find(sel).Each(func(_ int, s *goquery.Selection) {
+		nodes = append(nodes, cssNode{s})
+	})
+	return nodes, nil
+}
+
+func (n cssNode) text() string {
+	return n.Text()
+}
+
+func (n cssNode) attr(name string) (string, bool) {
+	return n.Attr(name)
+}
+
+func (n cssNode) html() (string, error) {
+	return n.Html()
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type binder struct {
+	// key is the option of tag holding the selector, like css.
+	key string
+}
+
+func (b binder) bind(n bindNode, i interface{}) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("bind needs a non-nil pointer to struct")
+	}
+	return b.bindStruct(n, v.Elem(), "")
+}
+
+func (b binder) bindStruct(n bindNode, v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		raw, ok := f.Tag.Lookup("goreq")
+		if !ok || raw == "-" || f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if path != "" {
+			name = path + "." + f.Name
+		}
+		tag, err := parseBindTag(b.key, raw)
+		if err != nil {
+			return &BindError{Field: name, Err: err}
+		}
+		if err := b.bindField(n, v.Field(i), tag, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b binder) bindField(n bindNode, v reflect.Value, tag bindTag, name string) error {
+	nodes := []bindNode{n}
+	if tag.sel != "" {
+		var err error
+		if nodes, err = n.find(tag.sel); err != nil {
+			return &BindError{Field: name, Err: err}
+		}
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		if len(nodes) == 0 {
+			if tag.required {
+				return &BindError{Field: name, Err: FieldRequiredErr}
+			}
+			return nil
+		}
+		s := reflect.MakeSlice(v.Type(), len(nodes), len(nodes))
+		for i := range nodes {
+			if err := b.bindOne(nodes[i], s.Index(i), tag, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	if len(nodes) == 0 {
+		if tag.required {
+			return &BindError{Field: name, Err: FieldRequiredErr}
+		}
+		return nil
+	}
+	return b.bindOne(nodes[0], v, tag, name)
+}
+
+func (b binder) bindOne(n bindNode, v reflect.Value, tag bindTag, name string) error {
+	if v.Kind() == reflect.Ptr {
+		e := reflect.New(v.Type().Elem())
+		if err := b.bindOne(n, e.Elem(), tag, name); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	}
+	if v.Kind() == reflect.Struct && v.Type() != timeType && !reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return b.bindStruct(n, v, name)
+	}
+
+	var str string
+	var err error
+	if tag.attr != "" {
+		str, _ = n.attr(tag.attr)
+	} else if tag.html {
+		str, err = n.html()
+	} else {
+		str = n.text()
+	}
+	if err != nil {
+		return &BindError{Field: name, Err: err}
+	}
+	str = strings.TrimSpace(str)
+	if str == "" {
+		if tag.required {
+			return &BindError{Field: name, Err: FieldRequiredErr}
+		}
+		return nil
+	}
+	if err := setBindValue(v, str, tag.layout); err != nil {
+		return &BindError{Field: name, Err: err}
+	}
+	return nil
+}
+
+// setBindValue converts str to the type of v.
+func setBindValue(v reflect.Value, str, layout string) error {
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) && v.Type() != timeType {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	}
+	switch {
+	case v.Type() == timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, str)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Slice:
+		v.SetBytes([]byte(str))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.ReplaceAll(str, ",", ""), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(strings.ReplaceAll(str, ",", ""), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(str, ",", ""), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("can't bind to %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(str))
+	default:
+		return fmt.Errorf("can't bind to %s", v.Type())
+	}
+	return nil
+}
+
+// BindHTML fills the struct i by css selectors in `goreq` tags of its fields. For example:
+//
+//	type Item struct {
+//		Title string    `goreq:"css=h2"`
+//		URL   string    `goreq:"css=a;attr=href;required"`
+//		Body  string    `goreq:"css=.body;html"`
+//		Price float64   `goreq:"css=.price"`
+//		Date  time.Time `goreq:"css=time;attr=datetime;layout=2006-01-02"`
+//	}
+//	var page struct {
+//		Items []Item `goreq:"css=.item"`
+//	}
+//
+// The text of the first matched element is used by default, attr uses an attribute and
+// html uses the inner HTML instead. Struct fields are filled by selectors inside the
+// matched element, and slices get one item for each matched element. An empty selector
+// means the current element. Fields marked required return a FieldRequiredErr when
+// nothing or an empty value matches.
+func (s *Response) BindHTML(i interface{}) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	h, err := s.HTML()
+	if err != nil {
+		return err
+	}
+	return binder{key: "css"}.bind(cssNode{h.Selection}, i)
+}
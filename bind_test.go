@@ -0,0 +1,106 @@
+package goreq
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const bindTestHTML = `<html><head><title> Shop </title></head><body>
+<div class="item" data-id="1">
+	<h2>Apple</h2><a href="/apple">more</a>
+	<span class="price">1,200.5</span><span class="stock">12</span>
+	<time datetime="2020-01-02">Jan 2</time>
+	<div class="desc"><b>red</b> fruit</div>
+	<ul><li>a</li><li>b</li></ul>
+</div>
+<div class="item" data-id="2">
+	<h2>Pear</h2><a href="/pear">more</a>
+	<span class="price">3</span>
+	<time datetime="2021-03-04">Mar 4</time>
+	<span class="ttl">1m30s</span>
+</div>
+</body></html>`
+
+func bindTestResp(t *testing.T) *Response {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, bindTestHTML)
+	}))
+	t.Cleanup(ts.Close)
+	return Get(ts.URL).Do()
+}
+
+func TestResponse_BindHTML(t *testing.T) {
+	type Item struct {
+		ID     int           `goreq:"attr=data-id"`
+		Name   string        `goreq:"css=h2;required"`
+		Link   string        `goreq:"css=a;attr=href"`
+		Price  float64       `goreq:"css=.price"`
+		Stock  *uint         `goreq:"css=.stock"`
+		Date   time.Time     `goreq:"css=time;attr=datetime;layout=2006-01-02"`
+		Desc   string        `goreq:"css=.desc;html"`
+		Tags   []string      `goreq:"css=li"`
+		TTL    time.Duration `goreq:"css=.ttl"`
+		ignore string
+	}
+	var page struct {
+		Title string `goreq:"css=title"`
+		Items []Item `goreq:"css=.item"`
+		First *Item  `goreq:"css=.item"`
+		None  string `goreq:"css=.none"`
+		Skip  string `goreq:"-"`
+	}
+	assert.NoError(t, bindTestResp(t).BindHTML(&page))
+	assert.Equal(t, "Shop", page.Title)
+	assert.Len(t, page.Items, 2)
+
+	a := page.Items[0]
+	assert.Equal(t, 1, a.ID)
+	assert.Equal(t, "Apple", a.Name)
+	assert.Equal(t, "/apple", a.Link)
+	assert.Equal(t, 1200.5, a.Price)
+	assert.Equal(t, uint(12), *a.Stock)
+	assert.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), a.Date)
+	assert.Equal(t, "<b>red</b> fruit", a.Desc)
+	assert.Equal(t, []string{"a", "b"}, a.Tags)
+
+	p := page.Items[1]
+	assert.Equal(t, 2, p.ID)
+	assert.Nil(t, p.Stock)
+	assert.Nil(t, p.Tags)
+	assert.Equal(t, 90*time.Second, p.TTL)
+	assert.Equal(t, "Apple", page.First.Name)
+	assert.Equal(t, "", page.None)
+}
+
+func TestResponse_BindHTMLErrors(t *testing.T) {
+	resp := bindTestResp(t)
+
+	var required struct {
+		Items []struct {
+			Stock int `goreq:"css=.stock;required"`
+		} `goreq:"css=.item"`
+	}
+	err := resp.BindHTML(&required)
+	assert.True(t, errors.Is(err, FieldRequiredErr))
+	assert.EqualError(t, err, "bind Items[1].Stock: required field is missing")
+
+	var conv struct {
+		Name int `goreq:"css=h2"`
+	}
+	err = resp.BindHTML(&conv)
+	var be *BindError
+	assert.True(t, errors.As(err, &be))
+	assert.Equal(t, "Name", be.Field)
+
+	var unknown struct {
+		Name string `goreq:"css=h2;foo"`
+	}
+	assert.Error(t, resp.BindHTML(&unknown))
+	assert.Error(t, resp.BindHTML(unknown))
+}
@@ -0,0 +1,156 @@
+package goreq
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BrowserProfile is a set of request headers sent by a real browser, listed in the order
+// the browser sends them.
+type BrowserProfile struct {
+	Name    string      `json:"name"`
+	Headers [][2]string `json:"headers"`
+}
+
+// UserAgent returns the User-Agent header of profile.
+func (p *BrowserProfile) UserAgent() string {
+	return p.Get("User-Agent")
+}
+
+// Get returns the value of header key in profile.
+func (p *BrowserProfile) Get(key string) string {
+	for _, h := range p.Headers {
+		if strings.EqualFold(h[0], key) {
+			return h[1]
+		}
+	}
+	return ""
+}
+
+// Apply sets the headers of profile to req. Headers already set on req are not changed.
+// Sec-* headers (client hints and fetch metadata) are only sent to https, like browsers do.
+// Headers are sent in the order of profile by SetHeaderOrder, unless req has an order.
+func (p *BrowserProfile) Apply(req *Request) *Request {
+	keys := make([]string, 0, len(p.Headers))
+	for _, h := range p.Headers {
+		if req.URL.Scheme != "https" && strings.HasPrefix(strings.ToLower(h[0]), "sec-") {
+			continue
+		}
+		keys = append(keys, h[0])
+		if req.Header.Get(h[0]) == "" {
+			req.Header.Set(h[0], h[1])
+		}
+	}
+	if len(headerOrder(req)) == 0 {
+		req.SetHeaderOrder(keys...)
+	}
+	return req
+}
+
+//go:embed browser_profiles.json
+var browserProfilesData []byte
+
+var browserProfiles = struct {
+	lock sync.RWMutex
+	list []*BrowserProfile
+}{}
+
+func init() {
+	if err := LoadBrowserProfiles(strings.NewReader(string(browserProfilesData))); err != nil {
+		panic(err)
+	}
+}
+
+// BrowserProfiles returns the registered profiles, the builtin ones are in browser_profiles.json.
+func BrowserProfiles() []*BrowserProfile {
+	browserProfiles.lock.RLock()
+	defer browserProfiles.lock.RUnlock()
+	return append([]*BrowserProfile{}, browserProfiles.list...)
+}
+
+// GetBrowserProfile returns the registered profile of name, or nil.
+func GetBrowserProfile(name string) *BrowserProfile {
+	browserProfiles.lock.RLock()
+	defer browserProfiles.lock.RUnlock()
+	for _, p := range browserProfiles.list {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// RegisterBrowserProfile registers profiles, which replace the registered ones with the same name.
+func RegisterBrowserProfile(profiles ...*BrowserProfile) {
+	browserProfiles.lock.Lock()
+	defer browserProfiles.lock.Unlock()
+next:
+	for _, p := range profiles {
+		for i, o := range browserProfiles.list {
+			if o.Name == p.Name {
+				browserProfiles.list[i] = p
+				continue next
+			}
+		}
+		browserProfiles.list = append(browserProfiles.list, p)
+	}
+}
+
+// LoadBrowserProfiles registers profiles in the JSON array read from r, in the format of
+// browser_profiles.json.
+func LoadBrowserProfiles(r io.Reader) error {
+	var profiles []*BrowserProfile
+	if err := json.NewDecoder(r).Decode(&profiles); err != nil {
+		return err
+	}
+	RegisterBrowserProfile(profiles...)
+	return nil
+}
+
+// WithBrowserProfile sends requests with headers of a random profile in profiles, all
+// registered profiles by default. Requests of a session by SetSession keep the profile
+// picked for their first request in the Session, until it's removed from SessionManager.
+// It takes effect when WithBrowserProfile is used before WithSessions, like
+// NewClient(WithBrowserProfile(), WithSessions(m)).
+func WithBrowserProfile(profiles ...*BrowserProfile) Middleware {
+	var lock sync.Mutex
+	ra := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			pick := func() *BrowserProfile {
+				list := profiles
+				if len(list) == 0 {
+					list = BrowserProfiles()
+				}
+				if len(list) == 0 {
+					return nil
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				return list[ra.Intn(len(list))]
+			}
+			m, _ := req.Context().Value(ctxSessionManager).(*SessionManager)
+			id, ok := req.Context().Value(ctxSession).(string)
+			if m == nil || !ok {
+				if p := pick(); p != nil {
+					p.Apply(req)
+				}
+				return h(req)
+			}
+			old, s := m.bindProfile(id, pick)
+			if s == nil || s.Profile == nil {
+				return h(req)
+			}
+			if old != s && req.Header.Get("User-Agent") == old.UserAgent {
+				// set by WithSessions before the session had a profile
+				req.Header.Del("User-Agent")
+			}
+			return h(s.Profile.Apply(req))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// restoreBrowserProfiles restores the registered profiles after the test.
+func restoreBrowserProfiles(t *testing.T) {
+	saved := BrowserProfiles()
+	t.Cleanup(func() {
+		browserProfiles.lock.Lock()
+		browserProfiles.list = saved
+		browserProfiles.lock.Unlock()
+	})
+}
+
+func TestBrowserProfiles(t *testing.T) {
+	restoreBrowserProfiles(t)
+	ps := BrowserProfiles()
+	assert.NotEmpty(t, ps)
+	for _, p := range ps {
+		assert.NotEmpty(t, p.Name)
+		assert.Contains(t, p.UserAgent(), "Mozilla/5.0", p.Name)
+		assert.NotEmpty(t, p.Get("Accept"), p.Name)
+		assert.NotEmpty(t, p.Get("Accept-Language"), p.Name)
+		if strings.Contains(p.UserAgent(), "Chrome/") {
+			assert.NotEmpty(t, p.Get("Sec-Ch-Ua"), p.Name)
+		}
+	}
+	p := GetBrowserProfile("chrome-windows")
+	if assert.NotNil(t, p) {
+		assert.Equal(t, "Sec-Ch-Ua", p.Headers[0][0])
+		assert.Equal(t, "\"Windows\"", p.Get("sec-ch-ua-platform"))
+	}
+	assert.Nil(t, GetBrowserProfile("unknown"))
+
+	assert.NoError(t, LoadBrowserProfiles(strings.NewReader(`[{"name":"test-bot","headers":[["User-Agent","bot/1.0"],["Accept","*/*"]]}]`)))
+	assert.Equal(t, "bot/1.0", GetBrowserProfile("test-bot").UserAgent())
+	RegisterBrowserProfile(&BrowserProfile{Name: "test-bot", Headers: [][2]string{{"User-Agent", "bot/2.0"}}})
+	assert.Equal(t, "bot/2.0", GetBrowserProfile("test-bot").UserAgent())
+	assert.Len(t, BrowserProfiles(), len(ps)+1)
+	assert.Error(t, LoadBrowserProfiles(strings.NewReader("{")))
+}
+
+func TestWithBrowserProfile(t *testing.T) {
+	restoreBrowserProfiles(t)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.UserAgent(), "|", r.Header.Get("Sec-Ch-Ua-Platform"), "|", r.Header.Get("Accept-Language"))
+	}))
+	defer ts.Close()
+	profiles := []*BrowserProfile{GetBrowserProfile("chrome-windows"), GetBrowserProfile("firefox-linux")}
+	c := NewClient(WithBrowserProfile(profiles...))
+
+	txt, err := Get(ts.URL).SetUA("custom").SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(txt, "custom|"))
+
+	// client hints are not sent to http
+	hs := httptest.NewServer(ts.Config.Handler)
+	defer hs.Close()
+	txt, _ = Get(hs.URL).SetClient(NewClient(WithBrowserProfile(profiles[0]))).Do().Txt()
+	assert.Equal(t, profiles[0].UserAgent()+"||en-US,en;q=0.9", txt)
+
+	// sessions keep their profile, even if registered profiles change
+	m := NewSessionManager(nil)
+	c = NewClient(WithBrowserProfile(), WithSessions(m))
+	for _, id := range []string{"1", "2", "3"} {
+		first, err := Get(ts.URL).SetSession(id).SetClient(c).Do().Txt()
+		assert.NoError(t, err)
+		p := m.Get(id).Profile
+		if !assert.NotNil(t, p) {
+			return
+		}
+		assert.True(t, strings.HasPrefix(first, p.UserAgent()+"|"), first)
+		assert.Equal(t, p.UserAgent(), m.Get(id).UserAgent)
+		for i := 0; i < 5; i++ {
+			RegisterBrowserProfile(&BrowserProfile{Name: fmt.Sprint("test-", id, i), Headers: [][2]string{{"User-Agent", "test"}}})
+			txt, _ := Get(ts.URL).SetSession(id).SetClient(c).Do().Txt()
+			assert.Equal(t, first, txt)
+		}
+	}
+	m.Remove("1")
+	_, _ = Get(ts.URL).SetSession("1").SetClient(c).Do().Txt()
+	assert.NotNil(t, m.Get("1").Profile)
+}
+
+func TestBrowserProfile_Apply(t *testing.T) {
+	p := &BrowserProfile{Headers: [][2]string{{"User-Agent", "ua"}, {"Sec-Ch-Ua", "x"}, {"Accept", "*/*"}}}
+	req := p.Apply(Get("https://example.com/"))
+	assert.Equal(t, []string{"User-Agent", "Sec-Ch-Ua", "Accept"}, headerOrder(req))
+	req = p.Apply(Get("http://example.com/"))
+	assert.Equal(t, []string{"User-Agent", "Accept"}, headerOrder(req))
+	req = p.Apply(Get("https://example.com/").SetHeaderOrder("Accept"))
+	assert.Equal(t, []string{"Accept"}, headerOrder(req))
+}
+
+func TestWithSessionsBrowserProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.UserAgent(), "|", r.Header.Get("Accept"))
+	}))
+	defer ts.Close()
+	p := GetBrowserProfile("safari-macos")
+	m := NewSessionManager(&SessionOpinion{Profiles: []*BrowserProfile{p}})
+	c := NewClient(WithSessions(m))
+	txt, err := Get(ts.URL).SetSession("1").SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, p.UserAgent()+"|"+p.Get("Accept"), txt)
+	assert.Equal(t, p, m.Get("1").Profile)
+	assert.Equal(t, p.UserAgent(), m.Get("1").UserAgent)
+}
@@ -0,0 +1,196 @@
+package goreq
+
+import (
+	"bytes"
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// EncodingSource tells where the character encoding of a Response came from.
+type EncodingSource string
+
+const (
+	// EncodingFromBOM is a byte order mark at the start of the body.
+	EncodingFromBOM EncodingSource = "bom"
+	// EncodingFromRequest is Request.RespEncode, which overrides everything but a BOM.
+	EncodingFromRequest EncodingSource = "request"
+	// EncodingFromHeader is the charset parameter of the Content-Type header.
+	EncodingFromHeader EncodingSource = "header"
+	// EncodingFromMeta is a <meta charset> or <meta http-equiv> in the first 1024 bytes of HTML.
+	EncodingFromMeta EncodingSource = "meta"
+	// EncodingFromXML is the encoding of the <?xml ?> declaration.
+	EncodingFromXML EncodingSource = "xml"
+	// EncodingFromDetector is guessed from the body by github.com/saintfish/chardet.
+	EncodingFromDetector EncodingSource = "detector"
+	// EncodingFromDefault is utf-8 for JSON and windows-1252 for others, used when nothing
+	// else is known.
+	EncodingFromDefault EncodingSource = "default"
+)
+
+// prescanLen is how many bytes are searched for a <meta> or <?xml ?> declaration.
+const prescanLen = 1024
+
+// detectLen is how many bytes are given to the detector.
+const detectLen = 64 * 1024
+
+var boms = []struct {
+	bom      []byte
+	encoding string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xfe, 0xff}, "utf-16be"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+}
+
+var xmlEncodingRe = regexp.MustCompile(`^<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// lookupEncoding returns the canonical name of label, or "" if it's not a known encoding.
+func lookupEncoding(label string) string {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return ""
+	}
+	if _, name := charset.Lookup(label); name != "" {
+		return name
+	}
+	// chardet names some encodings like GB-18030
+	_, name := charset.Lookup(strings.ReplaceAll(label, "-", ""))
+	return name
+}
+
+// isTextContent reports whether a body of the Content-Type should be decoded to text.
+// Without a Content-Type the body is sniffed by http.DetectContentType.
+func isTextContent(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "/json") || strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") ||
+		strings.HasSuffix(mediaType, "/javascript") || strings.HasSuffix(mediaType, "/ecmascript")
+}
+
+// prescanMeta looks for the encoding declared by <meta> in the head of an HTML document,
+// following the prescan algorithm of WHATWG HTML.
+func prescanMeta(head []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(head))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+			var httpEquiv, content, cs string
+			for more := true; more; {
+				var k, v []byte
+				k, v, more = z.TagAttr()
+				switch string(k) {
+				case "charset":
+					cs = string(v)
+				case "http-equiv":
+					httpEquiv = strings.ToLower(string(v))
+				case "content":
+					content = string(v)
+				}
+			}
+			if cs == "" && httpEquiv == "content-type" {
+				if _, params, err := mime.ParseMediaType(content); err == nil {
+					cs = params["charset"]
+				}
+			}
+			if name := lookupEncoding(cs); name != "" {
+				// a page served as utf-16 can't declare it by ASCII <meta>
+				if strings.HasPrefix(name, "utf-16") {
+					name = "utf-8"
+				}
+				return name
+			}
+		}
+	}
+}
+
+// sniffEncoding determines the encoding of body like a browser does: a BOM, the override
+// of request, the Content-Type header, a <meta> or <?xml ?> declaration, the detector and
+// finally a default. bom is the length of the BOM to skip.
+func sniffEncoding(body []byte, contentType, override string) (encoding string, source EncodingSource, bom int) {
+	for _, b := range boms {
+		if bytes.HasPrefix(body, b.bom) {
+			return b.encoding, EncodingFromBOM, len(b.bom)
+		}
+	}
+	if name := lookupEncoding(override); name != "" {
+		return name, EncodingFromRequest, 0
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if name := lookupEncoding(params["charset"]); name != "" {
+		return name, EncodingFromHeader, 0
+	}
+
+	head := body
+	if len(head) > prescanLen {
+		head = head[:prescanLen]
+	}
+	if m := xmlEncodingRe.FindSubmatch(head); m != nil {
+		if name := lookupEncoding(string(m[1])); name != "" {
+			return name, EncodingFromXML, 0
+		}
+	}
+	if mediaType == "" || strings.Contains(mediaType, "html") {
+		if name := prescanMeta(head); name != "" {
+			return name, EncodingFromMeta, 0
+		}
+	}
+
+	sample := body
+	if len(sample) > detectLen {
+		sample = sample[:detectLen]
+	}
+	if validUTF8Prefix(sample, len(body) > detectLen) {
+		return "utf-8", EncodingFromDetector, 0
+	}
+	if r, err := chardet.NewTextDetector().DetectBest(sample); err == nil {
+		if name := lookupEncoding(r.Charset); name != "" {
+			return name, EncodingFromDetector, 0
+		}
+	}
+	if strings.Contains(mediaType, "json") {
+		return "utf-8", EncodingFromDefault, 0
+	}
+	return "windows-1252", EncodingFromDefault, 0
+}
+
+// validUTF8Prefix is utf8.Valid, except that a rune cut at the end of a truncated sample
+// is allowed.
+func validUTF8Prefix(b []byte, truncated bool) bool {
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(b) > 0 && !utf8.RuneStart(b[len(b)-1]); i++ {
+			b = b[:len(b)-1]
+		}
+		if len(b) > 0 && b[len(b)-1] >= utf8.RuneSelf {
+			b = b[:len(b)-1]
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// decodeBytes converts b in the named encoding to UTF-8.
+func decodeBytes(b []byte, encoding string) ([]byte, error) {
+	if encoding == "utf-8" {
+		return b, nil
+	}
+	e, _ := charset.Lookup(encoding)
+	if e == nil {
+		return b, nil
+	}
+	return e.NewDecoder().Bytes(b)
+}
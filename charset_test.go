@@ -0,0 +1,82 @@
+package goreq
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponse_DecodeAndParse_Sniff(t *testing.T) {
+	gbk := func(s string) []byte {
+		b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+		return b
+	}
+	utf16le := func(s string) []byte {
+		b, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(s))
+		return b
+	}
+	cases := []struct {
+		name, contentType, override string
+		body                        []byte
+		text, encoding              string
+		source                      EncodingSource
+	}{
+		{"bom", "text/html; charset=gbk", "", append([]byte{0xef, 0xbb, 0xbf}, "<p>你好</p>"...), "<p>你好</p>", "utf-8", EncodingFromBOM},
+		{"utf16 bom", "text/plain", "", utf16le("你好"), "你好", "utf-16le", EncodingFromBOM},
+		{"request", "text/html; charset=utf-8", "gbk", gbk("你好"), "你好", "gbk", EncodingFromRequest},
+		{"header", "text/plain; charset=GB2312", "", gbk("你好"), "你好", "gbk", EncodingFromHeader},
+		{"meta", "text/html", "", gbk(`<html><head><meta charset="gbk"><title>你好</title>`), `<html><head><meta charset="gbk"><title>你好</title>`, "gbk", EncodingFromMeta},
+		{"http-equiv", "application/xhtml+xml", "", gbk(`<meta http-equiv="Content-Type" content="text/html; charset=gb2312"><p>你好</p>`), `<meta http-equiv="Content-Type" content="text/html; charset=gb2312"><p>你好</p>`, "gbk", EncodingFromMeta},
+		{"xml", "application/xml", "", gbk(`<?xml version="1.0" encoding="GBK"?><a>你好</a>`), `<?xml version="1.0" encoding="GBK"?><a>你好</a>`, "gbk", EncodingFromXML},
+		{"utf-8", "application/json", "", []byte(`{"a":"你好"}`), `{"a":"你好"}`, "utf-8", EncodingFromDetector},
+		{"no content type", "", "", []byte(`<p>你好</p>`), `<p>你好</p>`, "utf-8", EncodingFromDetector},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := &Response{
+				Response: &http.Response{Header: http.Header{}},
+				Req:      Get("http://example.com/").SetRespEncode(c.override),
+				Body:     c.body,
+			}
+			if c.contentType != "" {
+				resp.Header.Set("Content-Type", c.contentType)
+			}
+			assert.NoError(t, resp.DecodeAndParse())
+			assert.Equal(t, c.text, resp.Text)
+			assert.Equal(t, c.text, string(resp.Body))
+			assert.Equal(t, c.body, resp.RawBody)
+			assert.Equal(t, c.encoding, resp.Encoding)
+			assert.Equal(t, c.source, resp.EncodingSource)
+		})
+	}
+
+	// a binary body is not decoded
+	resp := &Response{
+		Response: &http.Response{Header: http.Header{"Content-Type": {"image/png"}}},
+		Req:      Get("http://example.com/"),
+		Body:     []byte{0x89, 'P', 'N', 'G', 0xff},
+	}
+	assert.NoError(t, resp.DecodeAndParse())
+	assert.Equal(t, "", resp.Text)
+	assert.Equal(t, "", resp.Encoding)
+	assert.Equal(t, resp.RawBody, resp.Body)
+}
+
+func TestClient_Do_Charset(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(`<meta charset="gbk"><title>标题</title>`))
+		_, _ = w.Write(b)
+	}))
+	defer ts.Close()
+	resp := Get(ts.URL).Do()
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "gbk", resp.Encoding)
+	assert.Equal(t, EncodingFromMeta, resp.EncodingSource)
+	h, err := resp.HTML()
+	assert.NoError(t, err)
+	assert.Equal(t, "标题", h.Find("title").Text())
+}
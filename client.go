@@ -1,12 +1,16 @@
 package goreq
 
 import (
+	"container/list"
 	"crypto/tls"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
 var DefaultClient = NewClient()
@@ -29,13 +33,18 @@ var ReqRejectedErr = errors.New("request is rejected")
 type Client struct {
 	Client  *http.Client
 	handler Handler
+
+	// transports are copies of the transport for proxies and header limits.
+	transports transportCache
+	sizeLimits SizeLimitOpinion
+	// saturationProbes report whether a limiter of client is full for a request.
+	saturationProbes []func(req *Request) bool
 }
 
 func NewClient(m ...Middleware) *Client {
-	j, _ := cookiejar.New(nil)
 	c := &Client{
 		Client: &http.Client{
-			Jar: j,
+			Jar: NewCookieJar(),
 			Transport: &http.Transport{
 				Proxy: func(req *http.Request) (*url.URL, error) {
 					if addr, ok := req.Context().Value(ctxProxy).(*url.URL); ok && addr != nil {
@@ -68,6 +77,17 @@ func (s *Client) Use(mid ...Middleware) *Client {
 	return s
 }
 
+// saturated reports whether any parallelism limiter of client is full for req, so that
+// another request would have to wait.
+func (s *Client) saturated(req *Request) bool {
+	for _, p := range s.saturationProbes {
+		if p(req) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Client) Do(req *Request) *Response {
 	if req.Err != nil {
 		return &Response{
@@ -82,8 +102,8 @@ func (s *Client) Do(req *Request) *Response {
 			Err: ReqRejectedErr,
 		}
 	}
-	if len(res.NotDecodedBody) == 0 && res.Err == nil {
-		res.Err = res.DecodeAndParse()
+	if res.Err == nil && decodeMode(req) == DecodeEager {
+		res.Err = res.decode()
 	}
 	return res
 }
@@ -96,19 +116,220 @@ func basicHttpDo(c *Client, next Handler) Handler {
 			Body: []byte{},
 		}
 
-		resp.Response, resp.Err = c.Client.Do(req.Request)
+		hc, err := c.httpClient(req)
+		if err != nil {
+			resp.Err = err
+			return resp
+		}
+		lim := c.sizeLimit(req)
+		resp.Response, resp.Err = hc.Do(prepareHttpRequest(req))
 		if resp.Err != nil {
+			resp.Err = headerLimitErr(resp.Err, lim.MaxHeaderSize)
+			return resp
+		}
+		if isStream(req) {
+			resp.Err = resp.openStream(lim)
 			return resp
 		}
 		defer resp.Response.Body.Close()
 
-		resp.Body, resp.Err = ioutil.ReadAll(resp.Response.Body)
+		body := limitReader(resp.Response.Body, LimitBody, lim.MaxBodySize)
+		if encodings, ok := contentEncodings(resp.Header); !ok || len(encodings) == 0 {
+			// the body isn't compressed, so it's the decompressed body as well
+			body = limitReader(body, LimitDecompressed, lim.MaxDecompressedSize)
+		}
+		resp.Body, resp.Err = readLimited(body)
 		if resp.Err != nil {
 			return resp
 		}
-		if resp.Err == nil {
-			resp.Err = resp.DecodeAndParse()
+		resp.Err = resp.decompress(lim.MaxDecompressedSize)
+		if resp.Err != nil {
+			return resp
+		}
+		if decodeMode(req) == DecodeEager {
+			resp.Err = resp.decode()
 		}
 		return resp
 	}
 }
+
+// httpClient returns the *http.Client to send req. Requests through SOCKS proxies are sent
+// by a copy of the transport, which dials by ProxyDialer. Copies are kept for each proxy,
+// up to maxTransports, so that connections are never shared between proxies. Requests
+// with a header size limit or a header order are sent by a copy too. Requests with their
+// own cookie jar are sent by a copy of the client using the jar.
+func (s *Client) httpClient(req *Request) (*http.Client, error) {
+	hc := s.Client
+	ordered := len(headerOrder(req)) > 0
+	u, _ := req.Context().Value(ctxProxy).(*url.URL)
+	if u != nil && !isDialerProxy(u) && !ordered {
+		u = nil
+	}
+	if maxHeader := s.sizeLimit(req).MaxHeaderSize; u != nil || maxHeader > 0 || ordered {
+		t, err := s.transport(u, maxHeader, ordered)
+		if err != nil {
+			return nil, err
+		}
+		c := *hc
+		c.Transport = t
+		hc = &c
+	}
+	if jar, ok := req.Context().Value(ctxCookieJar).(http.CookieJar); ok && jar != nil {
+		c := *hc
+		c.Jar = jar
+		hc = &c
+	}
+	return hc, nil
+}
+
+// transport returns the copy of transport of client, which dials by the proxy u if it
+// isn't nil, limits response headers to maxHeader if it's above zero and writes headers
+// in the order of request if ordered.
+func (s *Client) transport(u *url.URL, maxHeader int64, ordered bool) (*http.Transport, error) {
+	proxy := ""
+	if u != nil {
+		proxy = u.String()
+	}
+	key := fmt.Sprintf("%s %d %t", proxy, maxHeader, ordered)
+	if t := s.transports.get(key); t != nil {
+		return t, nil
+	}
+	base, ok := s.Client.Transport.(*http.Transport)
+	if !ok {
+		switch {
+		case u != nil:
+			return nil, fmt.Errorf("%s proxy requires the transport of client to be *http.Transport", u.Scheme)
+		case ordered:
+			return nil, errors.New("header order requires the transport of client to be *http.Transport")
+		}
+		return nil, errors.New("header size limit requires the transport of client to be *http.Transport")
+	}
+	pt := base.Clone()
+	if u != nil {
+		d, err := NewProxyDialer(proxy)
+		if err != nil {
+			return nil, err
+		}
+		pt.Proxy = nil
+		pt.DialContext = d.DialContext
+	}
+	if ordered {
+		orderHeaders(pt, pt.DialContext)
+	}
+	if maxHeader > 0 {
+		pt.MaxResponseHeaderBytes = maxHeader
+	}
+	if pt.IdleConnTimeout == 0 {
+		// connections in use when the copy is dropped are closed after idle
+		pt.IdleConnTimeout = 90 * time.Second
+	}
+	return s.transports.add(key, pt), nil
+}
+
+// maxTransports is how many copies of transport a client keeps. With rotating proxies the
+// least recently used copy is dropped and its idle connections are closed.
+const maxTransports = 64
+
+// transportCache keeps copies of transport by key in the order of use.
+type transportCache struct {
+	lock  sync.Mutex
+	list  list.List
+	items map[string]*list.Element
+}
+
+type transportEntry struct {
+	key string
+	t   *http.Transport
+}
+
+func (c *transportCache) get(key string) *http.Transport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.list.MoveToFront(e)
+		return e.Value.(*transportEntry).t
+	}
+	return nil
+}
+
+// add adds t unless another one is added for key, and returns the one kept.
+func (c *transportCache) add(key string, t *http.Transport) *http.Transport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.list.MoveToFront(e)
+		return e.Value.(*transportEntry).t
+	}
+	if c.items == nil {
+		c.items = map[string]*list.Element{}
+	}
+	c.items[key] = c.list.PushFront(&transportEntry{key: key, t: t})
+	for c.list.Len() > maxTransports {
+		e := c.list.Remove(c.list.Back()).(*transportEntry)
+		delete(c.items, e.key)
+		e.t.CloseIdleConnections()
+	}
+	return t
+}
+
+// prepareHttpRequest returns the *http.Request to send. Wrappers of body are applied
+// to a shallow copy so that the Request can be sent again.
+func prepareHttpRequest(req *Request) *http.Request {
+	r := req.Request
+	if keys := headerOrder(req); len(keys) > 0 {
+		r = r.Clone(r.Context())
+		r.Header.Set(headerOrderKey, strings.Join(keys, ","))
+	}
+	if enc, ok := r.Context().Value(ctxCompress).(string); ok && r.Body != nil && r.Body != http.NoBody {
+		getBody := r.GetBody
+		r = r.Clone(r.Context())
+		r.Header.Set("Content-Encoding", enc)
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		r.Body = compressBody(enc, r.Body)
+		if getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				b, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return compressBody(enc, b), nil
+			}
+		}
+	}
+	if fn, ok := r.Context().Value(ctxUploadProgress).(func(int64, int64)); ok && fn != nil && r.Body != nil {
+		if r == req.Request {
+			r = r.Clone(r.Context())
+		}
+		total := r.ContentLength
+		if total == 0 {
+			total = -1
+		}
+		r.Body = &progressReader{ReadCloser: r.Body, total: total, fn: fn}
+		if getBody := r.GetBody; getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				b, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return &progressReader{ReadCloser: b, total: total, fn: fn}, nil
+			}
+		}
+	}
+	return r
+}
+
+type progressReader struct {
+	io.ReadCloser
+	sent, total int64
+	fn          func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,188 @@
+package goreq
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+)
+
+// SupportedEncodings is the content codings goreq can compress and decompress.
+var SupportedEncodings = []string{EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd}
+
+type ctxCompressType struct{}
+
+var ctxCompress = &ctxCompressType{}
+
+// SetCompress compresses the request body with encoding (gzip, deflate, br or zstd)
+// while sending and sets the Content-Encoding header. The compressed body is sent chunked.
+func (s *Request) SetCompress(encoding string) *Request {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if !isSupportedEncoding(encoding) {
+		s.Err = fmt.Errorf("unsupported content encoding %q", encoding)
+		return s
+	}
+	return s.addContextValue(ctxCompress, encoding)
+}
+
+// SetAcceptEncoding sets the Accept-Encoding header. Responses in these encodings are
+// decompressed by goreq, the original bytes are kept in Response.CompressedBody.
+func (s *Request) SetAcceptEncoding(encodings ...string) *Request {
+	if len(encodings) == 0 {
+		encodings = SupportedEncodings
+	}
+	s.Header.Set("Accept-Encoding", strings.Join(encodings, ", "))
+	return s
+}
+
+// WithCompression asks servers for compressed responses in encodings,
+// all SupportedEncodings by default. Requests already have Accept-Encoding are not changed.
+func WithCompression(encodings ...string) Middleware {
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if req.Header.Get("Accept-Encoding") == "" {
+				req.SetAcceptEncoding(encodings...)
+			}
+			return h(req)
+		}
+	}
+}
+
+func isSupportedEncoding(encoding string) bool {
+	for _, e := range SupportedEncodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case EncodingDeflate:
+		return zlib.NewWriter(w), nil
+	case EncodingBrotli:
+		return brotli.NewWriter(w), nil
+	case EncodingZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+func newDecompressReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip, "x-gzip":
+		return gzip.NewReader(r)
+	case EncodingDeflate:
+		// "deflate" should be zlib wrapped, but some servers send raw deflate.
+		br := bufio.NewReader(r)
+		if head, err := br.Peek(2); err == nil && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 && head[0]&0x0f == 8 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case EncodingBrotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case EncodingZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// compressBody returns a reader which compresses body while it's read.
+func compressBody(encoding string, body io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+		w, err := newCompressWriter(encoding, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err = io.Copy(w, body); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Close())
+	}()
+	return pr
+}
+
+// contentEncodings returns the content codings of the response in the order they
+// should be removed. ok is false if any of them is not supported.
+func contentEncodings(h http.Header) (encodings []string, ok bool) {
+	for _, v := range h.Values("Content-Encoding") {
+		for _, e := range strings.Split(v, ",") {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if e == "" || e == "identity" {
+				continue
+			}
+			if e != "x-gzip" && !isSupportedEncoding(e) {
+				return nil, false
+			}
+			encodings = append([]string{e}, encodings...)
+		}
+	}
+	return encodings, true
+}
+
+// decompressReader wraps r to remove the content codings of response header h.
+// The header is updated as net/http does for transparent gzip.
+func decompressReader(resp *http.Response, r io.Reader) (io.Reader, error) {
+	encodings, ok := contentEncodings(resp.Header)
+	if !ok || len(encodings) == 0 {
+		return r, nil
+	}
+	for _, e := range encodings {
+		rc, err := newDecompressReader(e, r)
+		if err != nil {
+			return nil, err
+		}
+		r = rc
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return r, nil
+}
+
+// decompress removes the content codings of the response body. The original
+// bytes are kept in CompressedBody. The decompressed body is limited to max bytes if
+// it's above zero.
+func (s *Response) decompress(max int64) error {
+	if s.Response == nil || len(s.Body) == 0 {
+		return nil
+	}
+	raw := s.Body
+	r, err := decompressReader(s.Response, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	if _, ok := r.(*bytes.Reader); ok {
+		return nil
+	}
+	s.CompressedBody = raw
+	s.Body, err = readLimited(limitReader(r, LimitDecompressed, max))
+	return err
+}
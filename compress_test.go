@@ -0,0 +1,70 @@
+package goreq
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequest_SetCompress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := newDecompressReader(r.Header.Get("Content-Encoding"), r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, _ = io.Copy(w, body)
+	}))
+	defer ts.Close()
+	for _, enc := range SupportedEncodings {
+		txt, err := Post(ts.URL).SetRawBody([]byte(strings.Repeat("goreq", 100))).SetCompress(enc).Do().Txt()
+		assert.NoError(t, err, enc)
+		assert.Equal(t, strings.Repeat("goreq", 100), txt, enc)
+	}
+	assert.Error(t, Post(ts.URL).SetCompress("lzma").Do().Err)
+}
+
+func TestResponse_Decompress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Encoding", enc)
+		cw, err := newCompressWriter(enc, w)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, _ = io.WriteString(cw, strings.Repeat("hello", 100))
+		_ = cw.Close()
+	}))
+	defer ts.Close()
+	for _, enc := range SupportedEncodings {
+		resp := Get(ts.URL).SetAcceptEncoding(enc).Do()
+		assert.NoError(t, resp.Err, enc)
+		assert.Equal(t, strings.Repeat("hello", 100), resp.Text, enc)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"), enc)
+		assert.True(t, resp.Uncompressed, enc)
+
+		r, err := newDecompressReader(enc, strings.NewReader(string(resp.CompressedBody)))
+		assert.NoError(t, err, enc)
+		raw, err := ioutil.ReadAll(r)
+		assert.NoError(t, err, enc)
+		assert.Equal(t, resp.Body, raw, enc)
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("Accept-Encoding"))
+	}))
+	defer ts.Close()
+	c := NewClient(WithCompression())
+	txt, err := Get(ts.URL).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip, deflate, br, zstd", txt)
+	txt, err = Get(ts.URL).SetAcceptEncoding(EncodingBrotli).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "br", txt)
+}
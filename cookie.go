@@ -0,0 +1,382 @@
+package goreq
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/publicsuffix"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ctxCookieJarType struct{}
+
+var ctxCookieJar = &ctxCookieJarType{}
+
+// SetCookieJar sends the request with jar instead of the cookie jar of client.
+func (s *Request) SetCookieJar(jar http.CookieJar) *Request {
+	return s.addContextValue(ctxCookieJar, jar)
+}
+
+type CookieFormat uint8
+
+const (
+	// NetscapeCookies is the cookies.txt format of curl and wget.
+	NetscapeCookies CookieFormat = iota
+	// JSONCookies is an array of cookie objects, the format exported by browser extensions.
+	JSONCookies
+)
+
+// CookieJar is a cookie jar using the public suffix list, which can be saved to and
+// loaded from files.
+type CookieJar struct {
+	jar     *cookiejar.Jar
+	lock    sync.Mutex
+	entries map[string]*jarEntry
+}
+
+type jarEntry struct {
+	cookie   http.Cookie
+	domain   string
+	hostOnly bool
+	session  bool
+}
+
+func NewCookieJar() *CookieJar {
+	j, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &CookieJar{jar: j, entries: map[string]*jarEntry{}}
+}
+
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	host, err := canonicalCookieHost(u.Host)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for _, c := range cookies {
+		e := &jarEntry{cookie: *c, domain: host, hostOnly: true}
+		if d := strings.ToLower(strings.TrimPrefix(c.Domain, ".")); d != "" && d != host {
+			if !strings.HasSuffix(host, "."+d) || net.ParseIP(host) != nil {
+				continue
+			}
+			if ps, _ := publicsuffix.PublicSuffix(d); ps == d {
+				continue
+			}
+			e.domain, e.hostOnly = d, false
+		} else if d != "" {
+			e.hostOnly = false
+		}
+		if e.cookie.Path == "" || e.cookie.Path[0] != '/' {
+			e.cookie.Path = defaultCookiePath(u.Path)
+		}
+		key := e.domain + ";" + e.cookie.Path + ";" + e.cookie.Name
+		switch {
+		case c.MaxAge < 0:
+			delete(j.entries, key)
+			continue
+		case c.MaxAge > 0:
+			e.cookie.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case c.Expires.IsZero():
+			e.session = true
+		case !c.Expires.After(now):
+			delete(j.entries, key)
+			continue
+		}
+		e.cookie.MaxAge = 0
+		j.entries[key] = e
+	}
+}
+
+// All returns the unexpired cookies in jar. Domain of cookies is prefixed with a dot
+// unless they are host-only cookies, and Expires is zero for session cookies.
+func (j *CookieJar) All() []*http.Cookie {
+	var res []*http.Cookie
+	for _, e := range j.all() {
+		c := e.cookie
+		c.Domain = e.domain
+		if !e.hostOnly {
+			c.Domain = "." + e.domain
+		}
+		if e.session {
+			c.Expires = time.Time{}
+		}
+		res = append(res, &c)
+	}
+	return res
+}
+
+func (j *CookieJar) all() []*jarEntry {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	now := time.Now()
+	res := make([]*jarEntry, 0, len(j.entries))
+	for k, e := range j.entries {
+		if !e.session && !e.cookie.Expires.After(now) {
+			delete(j.entries, k)
+			continue
+		}
+		res = append(res, e)
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if res[a].domain != res[b].domain {
+			return res[a].domain < res[b].domain
+		}
+		if res[a].cookie.Path != res[b].cookie.Path {
+			return res[a].cookie.Path < res[b].cookie.Path
+		}
+		return res[a].cookie.Name < res[b].cookie.Name
+	})
+	return res
+}
+
+func (j *CookieJar) add(e *jarEntry) {
+	scheme := "http"
+	if e.cookie.Secure {
+		scheme = "https"
+	}
+	c := e.cookie
+	c.Domain = ""
+	if !e.hostOnly {
+		c.Domain = e.domain
+	}
+	if e.session {
+		c.Expires = time.Time{}
+	}
+	j.SetCookies(&url.URL{Scheme: scheme, Host: e.domain, Path: c.Path}, []*http.Cookie{&c})
+}
+
+// Save writes the cookies in jar to w.
+func (j *CookieJar) Save(w io.Writer, format CookieFormat) error {
+	switch format {
+	case NetscapeCookies:
+		bw := bufio.NewWriter(w)
+		_, _ = bw.WriteString("# Netscape HTTP Cookie File\n\n")
+		for _, e := range j.all() {
+			domain := e.domain
+			if !e.hostOnly {
+				domain = "." + domain
+			}
+			if e.cookie.HttpOnly {
+				domain = "#HttpOnly_" + domain
+			}
+			var expires int64
+			if !e.session {
+				expires = e.cookie.Expires.Unix()
+			}
+			_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, netscapeBool(!e.hostOnly),
+				e.cookie.Path, netscapeBool(e.cookie.Secure), expires, e.cookie.Name, e.cookie.Value)
+		}
+		return bw.Flush()
+	case JSONCookies:
+		var res []jsonCookie
+		for _, e := range j.all() {
+			c := jsonCookie{
+				Domain:   e.domain,
+				HostOnly: e.hostOnly,
+				Path:     e.cookie.Path,
+				Secure:   e.cookie.Secure,
+				HttpOnly: e.cookie.HttpOnly,
+				Session:  e.session,
+				Name:     e.cookie.Name,
+				Value:    e.cookie.Value,
+				SameSite: sameSiteName(e.cookie.SameSite),
+			}
+			if !e.hostOnly {
+				c.Domain = "." + c.Domain
+			}
+			if !e.session {
+				c.ExpirationDate = float64(e.cookie.Expires.UnixNano()) / 1e9
+			}
+			res = append(res, c)
+		}
+		if res == nil {
+			res = []jsonCookie{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	return fmt.Errorf("unknown cookie format %d", format)
+}
+
+// Load reads cookies from r into jar. Expired cookies are skipped.
+func (j *CookieJar) Load(r io.Reader, format CookieFormat) error {
+	switch format {
+	case NetscapeCookies:
+		sc := bufio.NewScanner(r)
+		line := 0
+		for sc.Scan() {
+			line += 1
+			text := strings.TrimRight(sc.Text(), "\r")
+			httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+			if httpOnly {
+				text = strings.TrimPrefix(text, "#HttpOnly_")
+			} else if strings.HasPrefix(text, "#") || strings.TrimSpace(text) == "" {
+				continue
+			}
+			f := strings.Split(text, "\t")
+			if len(f) != 7 {
+				return fmt.Errorf("invalid cookie at line %d", line)
+			}
+			expires, err := strconv.ParseInt(f[4], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid expiration at line %d: %w", line, err)
+			}
+			e := &jarEntry{
+				cookie: http.Cookie{
+					Name:     f[5],
+					Value:    f[6],
+					Path:     f[2],
+					Secure:   strings.EqualFold(f[3], "TRUE"),
+					HttpOnly: httpOnly,
+				},
+				domain:   strings.TrimPrefix(f[0], "."),
+				hostOnly: !strings.EqualFold(f[1], "TRUE"),
+				session:  expires == 0,
+			}
+			if !e.session {
+				e.cookie.Expires = time.Unix(expires, 0)
+			}
+			j.add(e)
+		}
+		return sc.Err()
+	case JSONCookies:
+		var cs []jsonCookie
+		if err := json.NewDecoder(r).Decode(&cs); err != nil {
+			return err
+		}
+		for _, c := range cs {
+			e := &jarEntry{
+				cookie: http.Cookie{
+					Name:     c.Name,
+					Value:    c.Value,
+					Path:     c.Path,
+					Secure:   c.Secure,
+					HttpOnly: c.HttpOnly,
+					SameSite: parseSameSite(c.SameSite),
+				},
+				domain:   strings.TrimPrefix(c.Domain, "."),
+				hostOnly: c.HostOnly,
+				session:  c.Session || c.ExpirationDate == 0,
+			}
+			if !e.session {
+				sec, frac := math.Modf(c.ExpirationDate)
+				e.cookie.Expires = time.Unix(int64(sec), int64(frac*1e9))
+			}
+			j.add(e)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown cookie format %d", format)
+}
+
+// SaveFile writes the cookies in jar to the file of path.
+func (j *CookieJar) SaveFile(path string, format CookieFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = j.Save(f, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadFile reads cookies from the file of path into jar. It's not an error that the
+// file doesn't exist.
+func (j *CookieJar) LoadFile(path string, format CookieFormat) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return j.Load(f, format)
+}
+
+type jsonCookie struct {
+	Domain         string  `json:"domain"`
+	HostOnly       bool    `json:"hostOnly"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	HttpOnly       bool    `json:"httpOnly"`
+	SameSite       string  `json:"sameSite,omitempty"`
+	Session        bool    `json:"session"`
+	ExpirationDate float64 `json:"expirationDate,omitempty"`
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "lax"
+	case http.SameSiteStrictMode:
+		return "strict"
+	case http.SameSiteNoneMode:
+		return "no_restriction"
+	}
+	return ""
+}
+
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none", "no_restriction":
+		return http.SameSiteNoneMode
+	}
+	return 0
+}
+
+func canonicalCookieHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return "", errors.New("empty host")
+	}
+	return host, nil
+}
+
+// defaultCookiePath is the default path of cookies described in RFC 6265 section 5.1.4.
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -0,0 +1,124 @@
+package goreq
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequest_SetCookieJar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.URL.Query().Get("set"); v != "" {
+			http.SetCookie(w, &http.Cookie{Name: "k", Value: v})
+		}
+		c, _ := r.Cookie("k")
+		if c != nil {
+			_, _ = fmt.Fprint(w, c.Value)
+		}
+	}))
+	defer ts.Close()
+	c := NewClient()
+	a, b := NewCookieJar(), NewCookieJar()
+	assert.NoError(t, Get(ts.URL+"?set=a").SetCookieJar(a).SetClient(c).Do().Err)
+	assert.NoError(t, Get(ts.URL+"?set=b").SetCookieJar(b).SetClient(c).Do().Err)
+
+	txt, _ := Get(ts.URL).SetCookieJar(a).SetClient(c).Do().Txt()
+	assert.Equal(t, "a", txt)
+	txt, _ = Get(ts.URL).SetCookieJar(b).SetClient(c).Do().Txt()
+	assert.Equal(t, "b", txt)
+	txt, _ = Get(ts.URL).SetClient(c).Do().Txt()
+	assert.Equal(t, "", txt)
+}
+
+func TestCookieJar_PublicSuffix(t *testing.T) {
+	j := NewCookieJar()
+	u, _ := url.Parse("https://www.example.co.uk/a/b")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "suffix", Value: "1", Domain: "co.uk"},
+		{Name: "domain", Value: "2", Domain: ".example.co.uk"},
+		{Name: "host", Value: "3"},
+		{Name: "other", Value: "4", Domain: "example.com"},
+	})
+	sub, _ := url.Parse("https://shop.example.co.uk/a/x")
+	cs := j.Cookies(sub)
+	assert.Len(t, cs, 1)
+	assert.Equal(t, "domain", cs[0].Name)
+
+	all := j.All()
+	assert.Len(t, all, 2)
+	assert.Equal(t, ".example.co.uk", all[0].Domain)
+	assert.Equal(t, "/a", all[0].Path)
+	assert.Equal(t, "www.example.co.uk", all[1].Domain)
+
+	j.SetCookies(u, []*http.Cookie{{Name: "host", MaxAge: -1}})
+	assert.Len(t, j.All(), 1)
+}
+
+func TestCookieJar_SaveLoad(t *testing.T) {
+	j := NewCookieJar()
+	u, _ := url.Parse("https://example.com/")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "s"},
+		{Name: "secure", Value: "x", Domain: "example.com", Secure: true, HttpOnly: true, MaxAge: 3600},
+		{Name: "lax", Value: "l", Path: "/p", SameSite: http.SameSiteLaxMode, Expires: time.Now().Add(time.Hour)},
+	})
+	for _, format := range []CookieFormat{NetscapeCookies, JSONCookies} {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, j.Save(buf, format))
+		n := NewCookieJar()
+		assert.NoError(t, n.Load(buf, format))
+		want, got := j.All(), n.All()
+		if !assert.Len(t, got, len(want)) {
+			continue
+		}
+		for i := range want {
+			assert.Equal(t, want[i].Name, got[i].Name)
+			assert.Equal(t, want[i].Value, got[i].Value)
+			assert.Equal(t, want[i].Domain, got[i].Domain)
+			assert.Equal(t, want[i].Path, got[i].Path)
+			assert.Equal(t, want[i].Secure, got[i].Secure)
+			assert.Equal(t, want[i].HttpOnly, got[i].HttpOnly)
+			assert.Equal(t, want[i].Expires.Unix(), got[i].Expires.Unix())
+		}
+		assert.Len(t, n.Cookies(u), 2)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	assert.NoError(t, NewCookieJar().LoadFile(path, JSONCookies))
+	assert.NoError(t, j.SaveFile(path, JSONCookies))
+	n := NewCookieJar()
+	assert.NoError(t, n.LoadFile(path, JSONCookies))
+	assert.Len(t, n.All(), 3)
+}
+
+func TestCookieJar_LoadNetscape(t *testing.T) {
+	txt := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"# https://curl.se/docs/http-cookies.html",
+		"",
+		".example.com\tTRUE\t/\tFALSE\t0\tsession\tv1",
+		"#HttpOnly_example.com\tFALSE\t/\tTRUE\t" + fmt.Sprint(time.Now().Add(time.Hour).Unix()) + "\thttponly\tv2",
+		"example.com\tFALSE\t/\tFALSE\t1000\texpired\tv3",
+	}, "\r\n")
+	j := NewCookieJar()
+	assert.NoError(t, j.Load(strings.NewReader(txt), NetscapeCookies))
+	all := j.All()
+	if assert.Len(t, all, 2) {
+		assert.Equal(t, "example.com", all[0].Domain)
+		assert.True(t, all[0].HttpOnly)
+		assert.True(t, all[0].Secure)
+		assert.Equal(t, ".example.com", all[1].Domain)
+		assert.True(t, all[1].Expires.IsZero())
+	}
+	u, _ := url.Parse("https://www.example.com/")
+	assert.Len(t, j.Cookies(u), 1)
+
+	assert.Error(t, j.Load(strings.NewReader("example.com\tFALSE\t/\n"), NetscapeCookies))
+}
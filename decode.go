@@ -0,0 +1,162 @@
+package goreq
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DecodeMode controls when the body of a response is decoded to UTF-8 text.
+type DecodeMode int
+
+const (
+	// DecodeEager decodes the body as soon as it's received. It's the default.
+	DecodeEager DecodeMode = iota
+	// DecodeLazy decodes the body on the first call of Txt, HTML, JSON and others that
+	// need text. The Text field is empty until then.
+	DecodeLazy
+	// DecodeNone never decodes the body, Body is the bytes as received. DecodeAndParse
+	// can still be called to decode it.
+	DecodeNone
+)
+
+type ctxDecodeType struct{}
+
+var ctxDecode = &ctxDecodeType{}
+
+// SetDecode sets when the response body is decoded, see DecodeMode.
+func (s *Request) SetDecode(m DecodeMode) *Request {
+	return s.addContextValue(ctxDecode, m)
+}
+
+// WithDecode sets the DecodeMode of requests which haven't one by SetDecode.
+func WithDecode(m DecodeMode) Middleware {
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if _, ok := req.Context().Value(ctxDecode).(DecodeMode); !ok {
+				req.SetDecode(m)
+			}
+			return h(req)
+		}
+	}
+}
+
+func decodeMode(req *Request) DecodeMode {
+	if req == nil || req.Request == nil {
+		return DecodeEager
+	}
+	m, _ := req.Context().Value(ctxDecode).(DecodeMode)
+	return m
+}
+
+// decodedBody is the result of decoding a body. It's shared by copies of a Response,
+// like the ones served by WithCache, so the body is decoded once for all of them.
+type decodedBody struct {
+	once     sync.Once
+	raw      []byte
+	body     []byte
+	text     string
+	encoding string
+	source   EncodingSource
+	err      error
+}
+
+func (d *decodedBody) decode(raw []byte, contentType, override string) {
+	d.raw, d.body = raw, raw
+	if len(raw) == 0 || !isTextContent(contentType, raw) {
+		return
+	}
+	var bom int
+	d.encoding, d.source, bom = sniffEncoding(raw, contentType, override)
+	body, err := decodeBytes(raw[bom:], d.encoding)
+	if err != nil {
+		d.err = err
+		return
+	}
+	d.body, d.text = body, string(body)
+}
+
+// decode decodes the body once, later calls return the same error. The body is decoded
+// without holding the lock of response.
+func (s *Response) decode() error {
+	m := s.mutex()
+	m.Lock()
+	if s.decoded || s.Err != nil {
+		defer m.Unlock()
+		return s.decodeErr()
+	}
+	if s.decodedBody == nil {
+		s.decodedBody = &decodedBody{}
+	}
+	d := s.decodedBody
+	raw, contentType, override := s.Body, "", ""
+	if s.Response != nil {
+		contentType = s.Header.Get("Content-Type")
+	}
+	if s.Req != nil {
+		override = s.Req.RespEncode
+	}
+	m.Unlock()
+
+	d.once.Do(func() {
+		d.decode(raw, contentType, override)
+	})
+
+	m.Lock()
+	defer m.Unlock()
+	if s.decoded {
+		return s.decodeErr()
+	}
+	s.decoded = true
+	if len(d.raw) == 0 {
+		return nil
+	}
+	s.RawBody, s.NotDecodedBody = d.raw, d.raw
+	s.Body = d.body
+	if d.encoding != "" {
+		s.Encoding, s.EncodingSource = d.encoding, d.source
+	}
+	if d.text != "" {
+		s.Text = d.text
+	}
+	return d.err
+}
+
+// decodeErr returns Err, or the error of decoding if the body is decoded. The lock of
+// response must be held.
+func (s *Response) decodeErr() error {
+	if s.Err == nil && s.decoded && s.decodedBody != nil {
+		return s.decodedBody.err
+	}
+	return s.Err
+}
+
+// lazyDecode decodes the body on first use if the request is DecodeLazy, and returns Err
+// read under the lock. A failure of decoding is kept in Err.
+func (s *Response) lazyDecode() error {
+	var err error
+	if decodeMode(s.Req) == DecodeLazy {
+		err = s.decode()
+	}
+	m := s.mutex()
+	m.Lock()
+	defer m.Unlock()
+	if s.Err == nil {
+		s.Err = err
+	}
+	return s.Err
+}
+
+// copy returns a copy of the response for another caller, like WithCache does. The
+// decoded and parsed body are shared with the copy, but not modified by it.
+func (s *Response) copy() *Response {
+	m := s.mutex()
+	m.Lock()
+	if s.decodedBody == nil {
+		s.decodedBody = &decodedBody{}
+	}
+	c := *s
+	m.Unlock()
+	c.lock = atomic.Value{}
+	c.parsedBody = s.parsed().share()
+	return &c
+}
@@ -0,0 +1,116 @@
+package goreq
+
+import (
+	"errors"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func gbkServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=gbk")
+		b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(`<title>标题</title>`))
+		_, _ = w.Write(b)
+	}))
+}
+
+func TestRequest_SetDecode(t *testing.T) {
+	ts := gbkServer()
+	defer ts.Close()
+
+	resp := Get(ts.URL).SetDecode(DecodeNone).Do()
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "", resp.Text)
+	assert.Equal(t, "", resp.Encoding)
+	txt, err := resp.Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "", txt)
+	assert.NoError(t, resp.DecodeAndParse())
+	assert.Equal(t, "<title>标题</title>", resp.Text)
+
+	resp = Get(ts.URL).SetDecode(DecodeLazy).Do()
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "", resp.Text)
+	assert.Nil(t, resp.RawBody)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := resp.HTML()
+			assert.NoError(t, err)
+			assert.Equal(t, "标题", h.Find("title").Text())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, "<title>标题</title>", resp.Text)
+	assert.Equal(t, "gbk", resp.Encoding)
+	raw := resp.RawBody
+
+	// decoding again does nothing
+	assert.NoError(t, resp.DecodeAndParse())
+	assert.Equal(t, raw, resp.RawBody)
+	assert.Equal(t, "<title>标题</title>", resp.Text)
+
+	c := NewClient(WithDecode(DecodeNone))
+	resp = Get(ts.URL).SetClient(c).Do()
+	assert.Equal(t, "", resp.Text)
+	resp = Get(ts.URL).SetClient(c).SetDecode(DecodeEager).Do()
+	assert.Equal(t, "<title>标题</title>", resp.Text)
+}
+
+func TestResponse_LazyDecodeErr(t *testing.T) {
+	decodeErr := errors.New("decode")
+	resp := &Response{Req: Get("http://example.com").SetDecode(DecodeLazy), Body: []byte("<a>1</a>")}
+	// decoding by x/text seldom fails, so the failure is planted as if it's decoded
+	resp.decodedBody = &decodedBody{raw: resp.Body, body: resp.Body, err: decodeErr}
+	resp.decodedBody.once.Do(func() {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resp.Txt()
+			assert.Equal(t, decodeErr, err)
+			_, err = resp.HTML()
+			assert.Equal(t, decodeErr, err)
+			_, err = resp.XML()
+			assert.Equal(t, decodeErr, err)
+			_, err = resp.JSON()
+			assert.Equal(t, decodeErr, err)
+			_, err = resp.XMLNode()
+			assert.Equal(t, decodeErr, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, decodeErr, resp.Err)
+	assert.Equal(t, decodeErr, resp.DecodeAndParse())
+}
+
+func TestWithCacheDecodeOnce(t *testing.T) {
+	ts := gbkServer()
+	defer ts.Close()
+	c := NewClient(WithCache(cache.New(time.Minute, time.Minute)), WithDecode(DecodeLazy))
+
+	first := Get(ts.URL).SetClient(c).Do()
+	second := Get(ts.URL).SetClient(c).Do()
+	assert.Equal(t, "", second.Text)
+	txt, err := first.Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "<title>标题</title>", txt)
+	assert.Equal(t, "", second.Text)
+
+	// the copy from cache uses the body decoded by the first response
+	assert.Same(t, first.decodedBody, second.decodedBody)
+	txt, err = second.Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "<title>标题</title>", txt)
+	assert.Equal(t, first.RawBody, second.RawBody)
+}
@@ -0,0 +1,236 @@
+package goreq
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+	nc        uint32
+}
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	"MD5":         md5.New,
+	"SHA-256":     sha256.New,
+	"SHA-512-256": sha512.New512_256,
+}
+
+// parseAuthParams parses the auth-params of a challenge like `realm="a", qop="auth,auth-int"`.
+func parseAuthParams(s string) map[string]string {
+	res := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return res
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return res
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			val = b.String()
+			s = s[min(i+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		res[key] = val
+	}
+}
+
+// parseDigestChallenge returns the strongest Digest challenge goreq supports in the
+// WWW-Authenticate headers, and whether it's marked stale.
+func parseDigestChallenge(h http.Header) (*digestChallenge, bool) {
+	var best *digestChallenge
+	var stale bool
+	rank := map[string]int{"MD5": 1, "SHA-256": 2, "SHA-512-256": 3}
+	for _, v := range h.Values("WWW-Authenticate") {
+		if len(v) < 7 || !strings.EqualFold(v[:7], "digest ") {
+			continue
+		}
+		p := parseAuthParams(v[7:])
+		c := &digestChallenge{
+			realm:     p["realm"],
+			nonce:     p["nonce"],
+			opaque:    p["opaque"],
+			algorithm: strings.ToUpper(p["algorithm"]),
+			userhash:  strings.EqualFold(p["userhash"], "true"),
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		if _, ok := digestAlgorithms[strings.TrimSuffix(c.algorithm, "-SESS")]; !ok || c.nonce == "" {
+			continue
+		}
+		if qop, ok := p["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				q = strings.TrimSpace(q)
+				if q == "auth" || q == "auth-int" && c.qop == "" {
+					c.qop = q
+				}
+			}
+			if c.qop == "" {
+				continue
+			}
+		}
+		if best == nil || rank[strings.TrimSuffix(c.algorithm, "-SESS")] > rank[strings.TrimSuffix(best.algorithm, "-SESS")] {
+			best = c
+			stale = strings.EqualFold(p["stale"], "true")
+		}
+	}
+	return best, stale
+}
+
+func digestHash(algorithm string, s ...string) string {
+	h := digestAlgorithms[strings.TrimSuffix(algorithm, "-SESS")]()
+	_, _ = io.WriteString(h, strings.Join(s, ":"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// digestResponse computes the request-digest of RFC 7616. body is only used by qop auth-int.
+func digestResponse(c *digestChallenge, username, password, method, uri, nc, cnonce string, body []byte) string {
+	ha1 := digestHash(c.algorithm, username, c.realm, password)
+	if strings.HasSuffix(c.algorithm, "-SESS") {
+		ha1 = digestHash(c.algorithm, ha1, c.nonce, cnonce)
+	}
+	ha2 := digestHash(c.algorithm, method, uri)
+	if c.qop == "auth-int" {
+		h := digestAlgorithms[strings.TrimSuffix(c.algorithm, "-SESS")]()
+		_, _ = h.Write(body)
+		ha2 = digestHash(c.algorithm, method, uri, hex.EncodeToString(h.Sum(nil)))
+	}
+	if c.qop == "" {
+		return digestHash(c.algorithm, ha1, c.nonce, ha2)
+	}
+	return digestHash(c.algorithm, ha1, c.nonce, nc, cnonce, c.qop, ha2)
+}
+
+type digestAuth struct {
+	username string
+	password string
+	lock     sync.Mutex
+	hosts    map[string]*digestChallenge
+}
+
+// authorize sets the Authorization header of req by the cached challenge of its host,
+// if there's one.
+func (d *digestAuth) authorize(req *Request) error {
+	d.lock.Lock()
+	c, ok := d.hosts[req.URL.Host]
+	if !ok {
+		d.lock.Unlock()
+		return nil
+	}
+	c.nc += 1
+	nc := fmt.Sprintf("%08x", c.nc)
+	cc := *c
+	d.lock.Unlock()
+
+	var body []byte
+	if cc.qop == "auth-int" && req.GetBody != nil {
+		b, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		body, err = ioutil.ReadAll(b)
+		_ = b.Close()
+		if err != nil {
+			return err
+		}
+	}
+	var cb [16]byte
+	_, _ = rand.Read(cb[:])
+	cnonce := hex.EncodeToString(cb[:])
+	uri := req.URL.RequestURI()
+	username := d.username
+	if cc.userhash {
+		username = digestHash(cc.algorithm, d.username, cc.realm)
+	}
+
+	v := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		username, cc.realm, cc.nonce, uri, strings.Replace(cc.algorithm, "-SESS", "-sess", 1),
+		digestResponse(&cc, d.username, d.password, req.Method, uri, nc, cnonce, body))
+	if cc.opaque != "" {
+		v += fmt.Sprintf(`, opaque=%q`, cc.opaque)
+	}
+	if cc.qop != "" {
+		v += fmt.Sprintf(`, qop=%s, nc=%s, cnonce=%q`, cc.qop, nc, cnonce)
+	}
+	if cc.userhash {
+		v += ", userhash=true"
+	}
+	req.addContextValue(ctxAuthorized, struct{}{}).Header.Set("Authorization", v)
+	return nil
+}
+
+// WithDigestAuth answers Digest challenges of servers. A request getting 401 with a Digest
+// challenge is sent again with the Authorization header if its body can be reset. The
+// challenge is cached for each host, so following requests are authorized at once.
+// MD5, SHA-256 and SHA-512-256 (with -sess variants) and qop auth and auth-int are supported.
+func WithDigestAuth(username, password string) Middleware {
+	d := &digestAuth{username: username, password: password, hosts: map[string]*digestChallenge{}}
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if req.Context().Value(ctxSkipAuth) != nil || hasUserAuth(req) {
+				return h(req)
+			}
+			if err := d.authorize(req); err != nil {
+				return &Response{Req: req, Err: err}
+			}
+			res := h(req)
+			if res == nil || res.Response == nil || res.StatusCode != http.StatusUnauthorized || !canReplay(req) {
+				return res
+			}
+			c, stale := parseDigestChallenge(res.Header)
+			if c == nil {
+				return res
+			}
+			d.lock.Lock()
+			d.hosts[req.URL.Host] = c
+			d.lock.Unlock()
+			if req.Debug {
+				log.Println("answer digest challenge of", req.URL.Host, "stale:", stale)
+			}
+			r, err := req.clone(req.Context())
+			if err != nil {
+				return res
+			}
+			if err = d.authorize(r); err != nil {
+				return res
+			}
+			return h(r)
+		}
+	}
+}
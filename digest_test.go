@@ -0,0 +1,126 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDigestResponse(t *testing.T) {
+	// RFC 2617 section 3.5
+	c := &digestChallenge{realm: "testrealm@host.com", nonce: "dcd98b7102dd2f0e8b11d0f600bfb0c093", algorithm: "MD5", qop: "auth"}
+	assert.Equal(t, "6629fae49393a05397450978507c4ef1",
+		digestResponse(c, "Mufasa", "Circle Of Life", "GET", "/dir/index.html", "00000001", "0a4f113b", nil))
+
+	// RFC 7616 section 3.9.1
+	c = &digestChallenge{realm: "http-auth@example.org", nonce: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", algorithm: "SHA-256", qop: "auth"}
+	cnonce := "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	assert.Equal(t, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		digestResponse(c, "Mufasa", "Circle of Life", "GET", "/dir/index.html", "00000001", cnonce, nil))
+	c.algorithm = "MD5"
+	assert.Equal(t, "8ca523f5e9506fed4657c9700eebdbec",
+		digestResponse(c, "Mufasa", "Circle of Life", "GET", "/dir/index.html", "00000001", cnonce, nil))
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	h := http.Header{}
+	h.Add("WWW-Authenticate", `Basic realm="x"`)
+	h.Add("WWW-Authenticate", `Digest realm="r", qop="auth-int, auth", nonce="n1", opaque="o", algorithm=MD5`)
+	h.Add("WWW-Authenticate", `Digest realm="a \"quoted\" realm", qop="auth-int", nonce="n2", algorithm=SHA-256-sess, stale=TRUE`)
+	h.Add("WWW-Authenticate", `Digest realm="r", nonce="n3", algorithm=unknown`)
+	c, stale := parseDigestChallenge(h)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, `a "quoted" realm`, c.realm)
+		assert.Equal(t, "n2", c.nonce)
+		assert.Equal(t, "SHA-256-SESS", c.algorithm)
+		assert.Equal(t, "auth-int", c.qop)
+	}
+	assert.True(t, stale)
+
+	h.Del("WWW-Authenticate")
+	h.Add("WWW-Authenticate", `Digest realm="r", qop="auth-int, auth", nonce="n1", opaque="o"`)
+	c, stale = parseDigestChallenge(h)
+	assert.Equal(t, "auth", c.qop)
+	assert.Equal(t, "MD5", c.algorithm)
+	assert.Equal(t, "o", c.opaque)
+	assert.False(t, stale)
+}
+
+// digestServer checks Digest authorization of user:pass, nonces expire after 3 uses.
+type digestServer struct {
+	*httptest.Server
+	lock       sync.Mutex
+	algorithm  string
+	qop        string
+	nonce      int
+	uses       int
+	lastNC     uint64
+	challenges int
+}
+
+func newDigestServer(algorithm, qop string) *digestServer {
+	s := &digestServer{algorithm: algorithm, qop: qop, nonce: 1}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		auth := r.Header.Get("Authorization")
+		stale := false
+		if len(auth) > 7 {
+			p := parseAuthParams(auth[7:])
+			nc, _ := strconv.ParseUint(p["nc"], 16, 32)
+			c := &digestChallenge{realm: "test", nonce: p["nonce"], algorithm: strings.ToUpper(s.algorithm), qop: p["qop"]}
+			ok := p["username"] == "user" && p["uri"] == r.URL.RequestURI() && p["opaque"] == "op" &&
+				p["response"] == digestResponse(c, "user", "pass", r.Method, p["uri"], p["nc"], p["cnonce"], body)
+			if ok && p["nonce"] == fmt.Sprint(s.nonce) && s.uses < 3 && nc > s.lastNC {
+				s.uses += 1
+				s.lastNC = nc
+				_, _ = fmt.Fprint(w, "ok ", string(body))
+				return
+			}
+			if ok {
+				stale = true
+				s.nonce += 1
+				s.uses = 0
+				s.lastNC = 0
+			}
+		}
+		s.challenges += 1
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", nonce="%d", opaque="op", algorithm=%s, qop="%s", stale=%v`,
+			s.nonce, s.algorithm, s.qop, stale))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	return s
+}
+
+func TestWithDigestAuth(t *testing.T) {
+	for _, v := range []struct{ algorithm, qop string }{
+		{"MD5", "auth"},
+		{"SHA-256", "auth"},
+		{"MD5-sess", "auth"},
+		{"SHA-256", "auth-int"},
+	} {
+		s := newDigestServer(v.algorithm, v.qop)
+		c := NewClient(WithDigestAuth("user", "pass"))
+		for i := 0; i < 7; i++ {
+			txt, err := Post(s.URL + "/p?q=1").SetRawBody([]byte(fmt.Sprint(i))).SetClient(c).Do().Txt()
+			assert.NoError(t, err, v)
+			assert.Equal(t, fmt.Sprint("ok ", i), txt, v)
+		}
+		// the first request and every expired nonce get a challenge
+		assert.Equal(t, 3, s.challenges, v)
+		s.Close()
+	}
+
+	s := newDigestServer("MD5", "auth")
+	defer s.Close()
+	resp := Get(s.URL).SetClient(NewClient(WithDigestAuth("user", "wrong"))).Do()
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
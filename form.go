@@ -0,0 +1,393 @@
+package goreq
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FormNotFoundErr is returned by Response.Form when no form matches the selector.
+var FormNotFoundErr = errors.New("form is not found")
+
+const (
+	formURLEncoded = "application/x-www-form-urlencoded"
+	formMultipart  = "multipart/form-data"
+	formTextPlain  = "text/plain"
+)
+
+// FormButton is a submit button of a form. Action, Method and Enctype are set by the
+// formaction, formmethod and formenctype attributes, and override those of the form.
+type FormButton struct {
+	Name, Value string
+	// Text is the label of a <button>.
+	Text            string
+	Action          *url.URL
+	Method, Enctype string
+	image           bool
+}
+
+// Form is a <form> of a page. Fields are pre-filled as a browser does: hidden inputs and
+// default values, checked checkboxes and radios, and selected options. Disabled controls
+// are left out.
+type Form struct {
+	// Action is the URL the form is submitted to, resolved like Links. It's the final URL
+	// of the page if the form has no action.
+	Action *url.URL
+	// Method is GET or POST in upper case.
+	Method string
+	// Enctype is application/x-www-form-urlencoded, multipart/form-data or text/plain.
+	Enctype string
+	Fields  []FormField
+	Files   []FormFile
+	Buttons []*FormButton
+
+	page   *Response
+	button *FormButton
+	err    error
+}
+
+func formMethod(m string) string {
+	if strings.EqualFold(strings.TrimSpace(m), http.MethodPost) {
+		return http.MethodPost
+	}
+	if strings.TrimSpace(m) == "" {
+		return ""
+	}
+	return http.MethodGet
+}
+
+func formEnctype(e string) string {
+	switch e = strings.ToLower(strings.TrimSpace(e)); e {
+	case formMultipart, formTextPlain, formURLEncoded:
+		return e
+	case "":
+		return ""
+	}
+	return formURLEncoded
+}
+
+// Forms returns the forms of the page in document order.
+func (s *Response) Forms() ([]*Form, error) {
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	base, page := s.baseURL(doc), s.URL()
+	controls := doc.Find("input, textarea, select, button")
+	var forms []*Form
+	doc.Find("form").Each(func(_ int, sel *goquery.Selection) {
+		f := &Form{
+			Action:  page,
+			Method:  http.MethodGet,
+			Enctype: formURLEncoded,
+			page:    s,
+		}
+		if action := strings.TrimSpace(sel.AttrOr("action", "")); action != "" {
+			if u, err := base.Parse(action); err == nil {
+				f.Action = u
+			}
+		}
+		if m := formMethod(sel.AttrOr("method", "")); m != "" {
+			f.Method = m
+		}
+		if e := formEnctype(sel.AttrOr("enctype", "")); e != "" {
+			f.Enctype = e
+		}
+		id := sel.AttrOr("id", "")
+		controls.Each(func(_ int, c *goquery.Selection) {
+			if owner, ok := c.Attr("form"); ok {
+				if id == "" || owner != id {
+					return
+				}
+			} else if !c.Closest("form").IsSelection(sel) {
+				return
+			}
+			f.addControl(c, base)
+		})
+		if len(f.Buttons) > 0 {
+			f.button = f.Buttons[0]
+		}
+		forms = append(forms, f)
+	})
+	return forms, nil
+}
+
+// Form returns the first form matching the css selector.
+func (s *Response) Form(selector string) (*Form, error) {
+	forms, err := s.Forms()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	i := doc.Find("form").IndexOfSelection(doc.Find(selector).Filter("form").First())
+	if i < 0 || i >= len(forms) {
+		return nil, FormNotFoundErr
+	}
+	return forms[i], nil
+}
+
+func (s *Form) addControl(c *goquery.Selection, base *url.URL) {
+	if _, disabled := c.Attr("disabled"); disabled {
+		return
+	}
+	if c.Closest("fieldset[disabled]").Length() > 0 {
+		return
+	}
+	name := c.AttrOr("name", "")
+	switch goquery.NodeName(c) {
+	case "input":
+		switch t := strings.ToLower(c.AttrOr("type", "text")); t {
+		case "submit", "image":
+			s.addButton(c, base, t == "image")
+		case "button", "reset", "file":
+		case "checkbox", "radio":
+			if _, checked := c.Attr("checked"); checked && name != "" {
+				s.Add(name, c.AttrOr("value", "on"))
+			}
+		default:
+			if name != "" {
+				s.Add(name, c.AttrOr("value", ""))
+			}
+		}
+	case "button":
+		if t := strings.ToLower(c.AttrOr("type", "submit")); t == "submit" {
+			s.addButton(c, base, false)
+		}
+	case "textarea":
+		if name != "" {
+			v := c.Text()
+			if strings.HasPrefix(v, "\r\n") {
+				v = v[2:]
+			} else if strings.HasPrefix(v, "\n") {
+				v = v[1:]
+			}
+			s.Add(name, v)
+		}
+	case "select":
+		if name == "" {
+			return
+		}
+		_, multiple := c.Attr("multiple")
+		options := c.Find("option").FilterFunction(func(_ int, o *goquery.Selection) bool {
+			_, disabled := o.Attr("disabled")
+			return !disabled
+		})
+		selected := options.Filter("[selected]")
+		if selected.Length() == 0 && !multiple {
+			selected = options.First()
+		} else if !multiple {
+			selected = selected.Last()
+		}
+		selected.Each(func(_ int, o *goquery.Selection) {
+			v, ok := o.Attr("value")
+			if !ok {
+				v = strings.Join(strings.Fields(o.Text()), " ")
+			}
+			s.Add(name, v)
+		})
+	}
+}
+
+func (s *Form) addButton(c *goquery.Selection, base *url.URL, image bool) {
+	b := &FormButton{
+		Name:    c.AttrOr("name", ""),
+		Value:   c.AttrOr("value", ""),
+		Method:  formMethod(c.AttrOr("formmethod", "")),
+		Enctype: formEnctype(c.AttrOr("formenctype", "")),
+		image:   image,
+	}
+	if goquery.NodeName(c) == "button" {
+		b.Text = strings.Join(strings.Fields(c.Text()), " ")
+	}
+	if action := strings.TrimSpace(c.AttrOr("formaction", "")); action != "" {
+		if u, err := base.Parse(action); err == nil {
+			b.Action = u
+		}
+	}
+	s.Buttons = append(s.Buttons, b)
+}
+
+// Get returns the first value of the field name.
+func (s *Form) Get(name string) string {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Set replaces the values of the field name by value.
+func (s *Form) Set(name, value string) *Form {
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			s.Fields[i].Value = value
+			s.Fields = append(s.Fields[:i+1], removeFields(s.Fields[i+1:], name)...)
+			return s
+		}
+	}
+	return s.Add(name, value)
+}
+
+// Add adds a value to the field name.
+func (s *Form) Add(name, value string) *Form {
+	s.Fields = append(s.Fields, FormField{Name: name, Value: value})
+	return s
+}
+
+// Del removes the field name.
+func (s *Form) Del(name string) *Form {
+	s.Fields = removeFields(s.Fields, name)
+	return s
+}
+
+func removeFields(fields []FormField, name string) []FormField {
+	var res []FormField
+	for _, f := range fields {
+		if f.Name != name {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// AddFile adds a file to upload. Only the file name is sent unless the form is
+// multipart/form-data.
+func (s *Form) AddFile(f FormFile) *Form {
+	s.Files = append(s.Files, f)
+	return s
+}
+
+// Click selects the submit button whose name, value or text is name, in that order of
+// precedence. The first submit button is selected by default like pressing Enter in a
+// browser.
+func (s *Form) Click(name string) *Form {
+	for _, match := range []func(b *FormButton) bool{
+		func(b *FormButton) bool { return b.Name == name },
+		func(b *FormButton) bool { return b.Value == name },
+		func(b *FormButton) bool { return b.Text == name },
+	} {
+		for _, b := range s.Buttons {
+			if match(b) {
+				s.button = b
+				return s
+			}
+		}
+	}
+	s.err = fmt.Errorf("button %q is not found", name)
+	return s
+}
+
+// NoButton submits the form without any button.
+func (s *Form) NoButton() *Form {
+	s.button = nil
+	return s
+}
+
+// values returns the fields to submit, including the selected button.
+func (s *Form) values() []FormField {
+	fields := append([]FormField{}, s.Fields...)
+	if b := s.button; b != nil && b.Name != "" {
+		if b.image {
+			fields = append(fields, FormField{Name: b.Name + ".x", Value: "0"}, FormField{Name: b.Name + ".y", Value: "0"})
+		} else {
+			fields = append(fields, FormField{Name: b.Name, Value: b.Value})
+		}
+	}
+	return fields
+}
+
+func encodeFormFields(fields []FormField) string {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(f.Name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(f.Value))
+	}
+	return b.String()
+}
+
+// Request builds the request submitting the form, with the page as Referer. It's sent by
+// the client, cookie jar and session of the request of the page.
+func (s *Form) Request() *Request {
+	action, method, enctype := s.Action, s.Method, s.Enctype
+	if b := s.button; b != nil {
+		if b.Action != nil {
+			action = b.Action
+		}
+		if b.Method != "" {
+			method = b.Method
+		}
+		if b.Enctype != "" {
+			enctype = b.Enctype
+		}
+	}
+	if action == nil {
+		action = &url.URL{}
+	}
+	u := *action
+	u.Fragment = ""
+
+	fields := s.values()
+	var req *Request
+	if method == http.MethodGet {
+		for _, f := range s.Files {
+			fields = append(fields, FormField{Name: f.FieldName, Value: f.FileName})
+		}
+		u.RawQuery = encodeFormFields(fields)
+		req = Get(u.String())
+	} else {
+		req = Post(u.String())
+		switch enctype {
+		case formMultipart:
+			var data []interface{}
+			for _, f := range fields {
+				data = append(data, f)
+			}
+			for _, f := range s.Files {
+				data = append(data, f)
+			}
+			req.SetMultipartBody(data...)
+		case formTextPlain:
+			var b strings.Builder
+			for _, f := range fields {
+				b.WriteString(f.Name + "=" + f.Value + "\r\n")
+			}
+			req.SetRawBody([]byte(b.String()))
+			req.AddHeader("Content-Type", "text/plain")
+		default:
+			for _, f := range s.Files {
+				fields = append(fields, FormField{Name: f.FieldName, Value: f.FileName})
+			}
+			req.SetRawBody([]byte(encodeFormFields(fields)))
+			req.AddHeader("Content-Type", formURLEncoded)
+		}
+	}
+	if s.page != nil {
+		if page := s.page.URL(); page != nil {
+			req.AddHeader("Referer", page.String())
+		}
+		if r := s.page.Req; r != nil {
+			req.SetClient(r.client).SetDebug(r.Debug)
+			if jar, ok := r.Context().Value(ctxCookieJar).(http.CookieJar); ok {
+				req.SetCookieJar(jar)
+			}
+			if id, ok := r.Context().Value(ctxSession).(string); ok {
+				req.SetSession(id)
+			}
+		}
+	}
+	if s.err != nil && req.Err == nil {
+		req.Err = s.err
+	}
+	return req
+}
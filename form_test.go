@@ -0,0 +1,105 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const formTestHTML = `<html><head><base href="/app/"></head><body>
+<form id="login" action="login#top" method="post">
+	<input type="hidden" name="csrf" value="t0k3n">
+	<input name="user" value="guest">
+	<input type="password" name="pass">
+	<input type="checkbox" name="remember" checked>
+	<input type="checkbox" name="agree" value="yes">
+	<input type="radio" name="lang" value="en">
+	<input type="radio" name="lang" value="zh" checked>
+	<input name="off" value="1" disabled>
+	<fieldset disabled><input name="off2" value="1"></fieldset>
+	<select name="plan"><option value="free">Free</option><option selected>Pro Plan</option></select>
+	<select name="tags" multiple><option value="a" selected>A</option><option value="b">B</option><option value="c" selected>C</option></select>
+	<textarea name="bio">
+hello</textarea>
+	<input type="file" name="avatar">
+	<input type="reset"><button type="button" name="x">x</button>
+	<input type="submit" name="do" value="Log in">
+	<button name="do" value="signup" formaction="/signup" formenctype="multipart/form-data">Sign up</button>
+</form>
+<input form="login" name="outside" value="o">
+<form action="/search"><input name="q"><input type="image" name="go"></form>
+</body></html>`
+
+func formTestServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1"})
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, formTestHTML)
+			return
+		case "/signup":
+			assert.NoError(t, r.ParseMultipartForm(1<<20))
+			f, h, err := r.FormFile("avatar")
+			assert.NoError(t, err)
+			b, _ := ioutil.ReadAll(f)
+			_, _ = fmt.Fprintf(w, "%s %s %s %s", r.FormValue("user"), r.FormValue("do"), h.Filename, b)
+			return
+		}
+		c, _ := r.Cookie("sid")
+		b, _ := ioutil.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%s %s %s %s %s %s", r.Method, r.URL.Path, r.URL.RawQuery, b, c, r.Referer())
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestForm(t *testing.T) {
+	ts := formTestServer(t)
+	c := NewClient()
+	page := Get(ts.URL + "/page").SetClient(c).Do()
+
+	f, err := page.Form("#login")
+	assert.NoError(t, err)
+	assert.Equal(t, ts.URL+"/app/login#top", f.Action.String())
+	assert.Equal(t, http.MethodPost, f.Method)
+	assert.Equal(t, []FormField{
+		{"csrf", "t0k3n"}, {"user", "guest"}, {"pass", ""}, {"remember", "on"}, {"lang", "zh"},
+		{"plan", "Pro Plan"}, {"tags", "a"}, {"tags", "c"}, {"bio", "hello"}, {"outside", "o"},
+	}, f.Fields)
+	assert.Len(t, f.Buttons, 2)
+	assert.Equal(t, "Sign up", f.Buttons[1].Text)
+
+	f.Set("user", "alice").Set("pass", "secret").Set("tags", "b").Del("bio").Add("extra", "a b")
+	assert.Equal(t, "alice", f.Get("user"))
+	txt, err := f.Request().Do().Txt()
+	assert.NoError(t, err)
+	body := "csrf=t0k3n&user=alice&pass=secret&remember=on&lang=zh&plan=Pro+Plan&tags=b&outside=o&extra=a+b&do=Log+in"
+	assert.Equal(t, "POST /app/login  "+body+" sid=s1 "+ts.URL+"/page", txt)
+
+	f.Click("Sign up").AddFile(FormFile{FieldName: "avatar", FileName: "a.png", File: strings.NewReader("PNG")})
+	txt, err = f.Request().Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "alice signup a.png PNG", txt)
+
+	assert.Error(t, f.Click("nope").Request().Err)
+
+	_, err = page.Form("#none")
+	assert.Equal(t, FormNotFoundErr, err)
+
+	forms, err := page.Forms()
+	assert.NoError(t, err)
+	search := forms[1]
+	assert.Equal(t, http.MethodGet, search.Method)
+	txt, err = search.Set("q", "go req").Request().Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "GET /search q=go+req&go.x=0&go.y=0  sid=s1 "+ts.URL+"/page", txt)
+
+	txt, err = search.NoButton().Request().Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "GET /search q=go+req  sid=s1 "+ts.URL+"/page", txt)
+}
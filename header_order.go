@@ -0,0 +1,238 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ctxHeaderOrderType struct{}
+
+var ctxHeaderOrder = &ctxHeaderOrderType{}
+
+// SetHeaderOrder sets the order of request headers on the wire. net/http writes headers
+// sorted by name, so requests with an order are sent by a copy of the transport which
+// reorders them while writing. Host stays first unless it's listed, headers not listed
+// follow in the order of net/http.
+//
+// The order is kept over HTTP/1.1 only. Such requests never use HTTP/2, proxies set by
+// SetProxy are tunneled by CONNECT, and Response.TLS is nil.
+func (s *Request) SetHeaderOrder(keys ...string) *Request {
+	return s.addContextValue(ctxHeaderOrder, keys)
+}
+
+func headerOrder(req *Request) []string {
+	keys, _ := req.Context().Value(ctxHeaderOrder).([]string)
+	return keys
+}
+
+// headerOrderKey carries the order of headers from prepareHttpRequest to headerOrderConn,
+// which removes it.
+const headerOrderKey = "Goreq-Header-Order"
+
+// maxOrderedHead is how large a request head is buffered to be reordered, a larger one
+// is written as it is.
+const maxOrderedHead = 1 << 20
+
+const (
+	orderHead = iota
+	orderBody
+	orderChunkSize
+	orderChunkData
+	orderTrailer
+	orderRaw
+)
+
+// headerOrderConn writes HTTP/1.1 requests to Conn with headers reordered by headerOrderKey.
+// It follows the framing of request bodies to find the next request on the connection,
+// and writes everything as it is after a protocol upgrade or anything it can't parse.
+type headerOrderConn struct {
+	net.Conn
+	state int
+	head  []byte
+	line  []byte
+	// left is the bytes left of the body, or of the chunk with its CRLF.
+	left int64
+}
+
+func (c *headerOrderConn) Write(p []byte) (int, error) {
+	n := len(p)
+	var out []byte
+	for len(p) > 0 {
+		switch c.state {
+		case orderHead:
+			c.head = append(c.head, p...)
+			p = nil
+			i := bytes.Index(c.head, []byte("\r\n\r\n"))
+			if i < 0 {
+				if len(c.head) > maxOrderedHead {
+					out = append(out, c.head...)
+					c.head, c.state = nil, orderRaw
+				}
+				continue
+			}
+			out = append(out, c.reorder(c.head[:i+4])...)
+			p, c.head = c.head[i+4:], nil
+		case orderBody, orderChunkData:
+			k := int64(len(p))
+			if k > c.left {
+				k = c.left
+			}
+			out = append(out, p[:k]...)
+			p = p[k:]
+			if c.left -= k; c.left == 0 {
+				if c.state == orderBody {
+					c.state = orderHead
+				} else {
+					c.state = orderChunkSize
+				}
+			}
+		case orderChunkSize, orderTrailer:
+			i := bytes.IndexByte(p, '\n')
+			if i < 0 {
+				c.line = append(c.line, p...)
+				out = append(out, p...)
+				p = nil
+				continue
+			}
+			c.line = append(c.line, p[:i+1]...)
+			out = append(out, p[:i+1]...)
+			p = p[i+1:]
+			line := strings.TrimSpace(string(c.line))
+			c.line = c.line[:0]
+			if c.state == orderTrailer {
+				if line == "" {
+					c.state = orderHead
+				}
+				continue
+			}
+			if j := strings.IndexByte(line, ';'); j >= 0 {
+				line = strings.TrimSpace(line[:j])
+			}
+			size, err := strconv.ParseInt(line, 16, 64)
+			switch {
+			case err != nil || size < 0:
+				c.state = orderRaw
+			case size == 0:
+				c.state = orderTrailer
+			default:
+				c.state, c.left = orderChunkData, size+2
+			}
+		default:
+			out = append(out, p...)
+			p = nil
+		}
+	}
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// reorder reorders the header fields of head and sets the state for the body.
+func (c *headerOrderConn) reorder(head []byte) []byte {
+	lines := strings.Split(string(head[:len(head)-4]), "\r\n")
+	rank := map[string]int{"host": -1}
+	fields := make([]string, 0, len(lines)-1)
+	var length int64
+	chunked, upgrade, last := false, false, 0
+	for _, l := range lines[1:] {
+		k, v := l, ""
+		if i := strings.IndexByte(l, ':'); i >= 0 {
+			k, v = l[:i], strings.TrimSpace(l[i+1:])
+		}
+		switch textproto.CanonicalMIMEHeaderKey(k) {
+		case headerOrderKey:
+			keys := strings.Split(v, ",")
+			for i, key := range keys {
+				key = strings.ToLower(strings.TrimSpace(key))
+				if _, ok := rank[key]; !ok || key == "host" {
+					rank[key] = i
+				}
+			}
+			last = len(keys)
+			continue
+		case "Content-Length":
+			length, _ = strconv.ParseInt(v, 10, 64)
+		case "Transfer-Encoding":
+			chunked = strings.Contains(strings.ToLower(v), "chunked")
+		case "Upgrade":
+			upgrade = true
+		}
+		fields = append(fields, l)
+	}
+	rankOf := func(l string) int {
+		if i := strings.IndexByte(l, ':'); i >= 0 {
+			if r, ok := rank[strings.ToLower(l[:i])]; ok {
+				return r
+			}
+		}
+		return last
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return rankOf(fields[i]) < rankOf(fields[j])
+	})
+
+	switch {
+	case upgrade:
+		c.state = orderRaw
+	case chunked:
+		c.state = orderChunkSize
+	case length > 0:
+		c.state, c.left = orderBody, length
+	default:
+		c.state = orderHead
+	}
+	return []byte(lines[0] + "\r\n" + strings.Join(fields, "\r\n") + "\r\n\r\n")
+}
+
+// orderHeaders makes pt write headers in the order of headerOrderKey. Connections are
+// dialed by dial, and TLS is done by pt so that headers are reordered before encryption.
+func orderHeaders(pt *http.Transport, dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	pt.Proxy = nil
+	pt.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &headerOrderConn{Conn: conn}, nil
+	}
+	pt.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := pt.TLSClientConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		cfg = cfg.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		cfg.NextProtos = []string{"http/1.1"}
+		if pt.TLSHandshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, pt.TLSHandshakeTimeout)
+			defer cancel()
+		}
+		tc := tls.Client(conn, cfg)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return &headerOrderConn{Conn: tc}, nil
+	}
+}
@@ -0,0 +1,115 @@
+package goreq
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type writeRecorder struct {
+	net.Conn
+	b strings.Builder
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	return w.b.Write(p)
+}
+
+func TestHeaderOrderConn(t *testing.T) {
+	w := &writeRecorder{}
+	c := &headerOrderConn{Conn: w}
+	reqs := "POST / HTTP/1.1\r\nHost: a\r\nB: 1\r\nContent-Length: 5\r\nGoreq-Header-Order: c,b,content-length\r\nC: 2\r\n\r\nhello" +
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\nB: 1\r\nGoreq-Header-Order: b\r\n\r\n" +
+		"3;x=y\r\nabc\r\n0\r\nT: 1\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: a\r\nB: 1\r\nA: 2\r\nGoreq-Header-Order: a,host\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: a\r\nUpgrade: websocket\r\nB: 1\r\nA: 2\r\nGoreq-Header-Order: a\r\n\r\n" +
+		"raw\r\n\r\nGoreq-Header-Order: a\r\n"
+	// written in small pieces, as a head may be split across writes
+	for i := 0; i < len(reqs); i += 7 {
+		j := i + 7
+		if j > len(reqs) {
+			j = len(reqs)
+		}
+		n, err := c.Write([]byte(reqs[i:j]))
+		assert.NoError(t, err)
+		assert.Equal(t, j-i, n)
+	}
+	assert.Equal(t, "POST / HTTP/1.1\r\nHost: a\r\nC: 2\r\nB: 1\r\nContent-Length: 5\r\n\r\nhello"+
+		"POST / HTTP/1.1\r\nHost: a\r\nB: 1\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"3;x=y\r\nabc\r\n0\r\nT: 1\r\n\r\n"+
+		"GET / HTTP/1.1\r\nA: 2\r\nHost: a\r\nB: 1\r\n\r\n"+
+		"GET / HTTP/1.1\r\nHost: a\r\nA: 2\r\nUpgrade: websocket\r\nB: 1\r\n\r\n"+
+		"raw\r\n\r\nGoreq-Header-Order: a\r\n", w.b.String())
+}
+
+// rawHeadServer answers each request with the names of its header fields in the order
+// they are received.
+func rawHeadServer(t *testing.T, l net.Listener) {
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					var names []string
+					for {
+						line, err := br.ReadString('\n')
+						if err != nil {
+							return
+						}
+						line = strings.TrimRight(line, "\r\n")
+						if line == "" {
+							break
+						}
+						if i := strings.IndexByte(line, ':'); i >= 0 {
+							names = append(names, line[:i])
+						}
+					}
+					body := strings.Join(names, ",")
+					_, _ = fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+				}
+			}()
+		}
+	}()
+	t.Cleanup(func() { _ = l.Close() })
+}
+
+func TestRequest_SetHeaderOrder(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	rawHeadServer(t, l)
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	tl, err := tls.Listen("tcp", "127.0.0.1:0", ts.TLS)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rawHeadServer(t, tl)
+
+	c := NewClient()
+	for _, u := range []string{"http://" + l.Addr().String(), "https://" + tl.Addr().String()} {
+		for i := 0; i < 2; i++ {
+			txt, err := Get(u).SetClient(c).AddHeader("Accept", "*/*").SetUA("ua").
+				AddHeader("X-B", "1").AddHeader("X-A", "1").
+				SetHeaderOrder("X-B", "User-Agent", "x-a").Do().Txt()
+			assert.NoError(t, err)
+			assert.Equal(t, "Host,X-B,User-Agent,X-A,Accept,Accept-Encoding", txt)
+		}
+		// requests without an order are sent by net/http as usual
+		txt, err := Get(u).SetClient(c).AddHeader("X-B", "1").AddHeader("X-A", "1").SetUA("ua").Do().Txt()
+		assert.NoError(t, err)
+		assert.Equal(t, "Host,User-Agent,X-A,X-B,Accept-Encoding", txt)
+	}
+}
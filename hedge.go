@@ -0,0 +1,206 @@
+package goreq
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type HedgingOpinion struct {
+	// Percentile of recent latencies to wait before sending a hedged request, 0.95 by default.
+	Percentile float64
+	// InitialDelay is used before there are enough latencies, 100ms by default.
+	InitialDelay time.Duration
+	// MinDelay is the lower bound of the delay.
+	MinDelay time.Duration
+	// MaxHedges is how many hedged requests a request can have at most, 1 by default.
+	MaxHedges int
+	// MaxExtraLoad limits hedged requests to this ratio of all requests, 0.1 by default.
+	MaxExtraLoad float64
+	// Window is how many recent latencies are kept, 1000 by default.
+	Window int
+	// Methods can be hedged, GET, HEAD and OPTIONS by default since they're idempotent.
+	Methods []string
+	// IsRespOk tells whether a response wins the race. By default any response without
+	// error wins. A request returns the last response if none of them is ok.
+	IsRespOk func(*Response) bool
+}
+
+type hedger struct {
+	opt       HedgingOpinion
+	lock      sync.Mutex
+	latencies []time.Duration
+	next      int
+	tokens    float64
+}
+
+// hedgeMinSamples is how many latencies are needed to use the percentile.
+const hedgeMinSamples = 10
+
+func (s *hedger) record(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.latencies) < s.opt.Window {
+		s.latencies = append(s.latencies, d)
+		return
+	}
+	s.latencies[s.next] = d
+	s.next = (s.next + 1) % s.opt.Window
+}
+
+func (s *hedger) delay() time.Duration {
+	s.lock.Lock()
+	if len(s.latencies) < hedgeMinSamples {
+		s.lock.Unlock()
+		return s.opt.InitialDelay
+	}
+	l := append([]time.Duration{}, s.latencies...)
+	s.lock.Unlock()
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	d := l[int(math.Ceil(float64(len(l))*s.opt.Percentile))-1]
+	if d < s.opt.MinDelay {
+		d = s.opt.MinDelay
+	}
+	return d
+}
+
+// deposit earns a request MaxExtraLoad tokens, withdraw spends one token for a hedged request.
+func (s *hedger) deposit() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tokens = math.Min(s.tokens+s.opt.MaxExtraLoad, math.Max(1, 10*s.opt.MaxExtraLoad))
+}
+
+func (s *hedger) withdraw() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens -= 1
+	return true
+}
+
+func (s *hedger) ok(res *Response) bool {
+	if res == nil || res.Err != nil {
+		return false
+	}
+	return s.opt.IsRespOk == nil || s.opt.IsRespOk(res)
+}
+
+func (s *hedger) hedgeable(req *Request) bool {
+	if isStream(req) || !canReplay(req) {
+		return false
+	}
+	return containsString(s.opt.Methods, req.Method)
+}
+
+type hedgeResult struct {
+	res     *Response
+	latency time.Duration
+}
+
+// WithHedging sends a hedged copy of a request if it hasn't succeeded after a delay, the
+// percentile of recent latencies, and returns whichever succeeds first. Other requests
+// are canceled. Requests are not hedged if any parallelism limiter of the client is full
+// for them, or hedged requests would exceed MaxExtraLoad. Streaming requests and requests
+// whose body can't be reset are never hedged.
+func WithHedging(opt *HedgingOpinion) Middleware {
+	s := &hedger{}
+	if opt != nil {
+		s.opt = *opt
+	}
+	if s.opt.Percentile <= 0 || s.opt.Percentile > 1 {
+		s.opt.Percentile = 0.95
+	}
+	if s.opt.InitialDelay <= 0 {
+		s.opt.InitialDelay = 100 * time.Millisecond
+	}
+	if s.opt.MaxHedges <= 0 {
+		s.opt.MaxHedges = 1
+	}
+	if s.opt.MaxExtraLoad <= 0 {
+		s.opt.MaxExtraLoad = 0.1
+	}
+	if s.opt.Window <= 0 {
+		s.opt.Window = 1000
+	}
+	if len(s.opt.Methods) == 0 {
+		s.opt.Methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if !s.hedgeable(req) {
+				start := time.Now()
+				res := h(req)
+				if s.ok(res) {
+					s.record(time.Since(start))
+				}
+				return res
+			}
+			s.deposit()
+
+			results := make(chan hedgeResult, s.opt.MaxHedges+1)
+			var cancels []context.CancelFunc
+			defer func() {
+				for _, cancel := range cancels {
+					cancel()
+				}
+			}()
+			send := func() error {
+				ctx, cancel := context.WithCancel(req.Context())
+				r, err := req.clone(ctx)
+				if err != nil {
+					cancel()
+					return err
+				}
+				cancels = append(cancels, cancel)
+				start := time.Now()
+				go func() {
+					res := h(r)
+					results <- hedgeResult{res: res, latency: time.Since(start)}
+				}()
+				return nil
+			}
+			if err := send(); err != nil {
+				return &Response{Req: req, Err: err}
+			}
+
+			pending, hedges := 1, 0
+			timer := time.NewTimer(s.delay())
+			defer timer.Stop()
+			var last *Response
+			for pending > 0 {
+				select {
+				case r := <-results:
+					pending -= 1
+					if r.res != nil {
+						r.res.Req = req
+					}
+					if s.ok(r.res) {
+						s.record(r.latency)
+						return r.res
+					}
+					last = r.res
+				case <-timer.C:
+					if hedges >= s.opt.MaxHedges || x.saturated(req) || !s.withdraw() {
+						continue
+					}
+					if req.Debug {
+						log.Println("send hedged request", hedges+1, "of", req.URL)
+					}
+					if send() == nil {
+						pending += 1
+						hedges += 1
+						timer.Reset(s.delay())
+					}
+				}
+			}
+			return last
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowOddServer answers odd requests in 1 second and even requests at once. An odd
+// request canceled by the client is sent to canceled.
+func slowOddServer(calls *int32, canceled chan<- struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		if n%2 == 1 {
+			select {
+			case <-r.Context().Done():
+				select {
+				case canceled <- struct{}{}:
+				default:
+				}
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		_, _ = fmt.Fprint(w, n)
+	}))
+}
+
+func TestWithHedging(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{}, 1)
+	ts := slowOddServer(&calls, canceled)
+	defer ts.Close()
+	c := NewClient(WithHedging(&HedgingOpinion{InitialDelay: 50 * time.Millisecond, MaxExtraLoad: 1}))
+
+	start := time.Now()
+	txt, err := Get(ts.URL).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "2", txt)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("the slower request was not canceled")
+	}
+
+	// requests not idempotent are not hedged
+	atomic.StoreInt32(&calls, 0)
+	txt, err = Post(ts.URL).SetRawBody([]byte("a")).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", txt)
+}
+
+func TestWithHedgingExtraLoad(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(30 * time.Millisecond):
+		}
+	}))
+	defer ts.Close()
+	c := NewClient(WithHedging(&HedgingOpinion{InitialDelay: 10 * time.Millisecond, MaxExtraLoad: 0.2}))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, Get(ts.URL).SetClient(c).Do().Err)
+	}
+	// MaxExtraLoad 0.2 allows at most 2 hedged requests for 10 requests
+	assert.True(t, atomic.LoadInt32(&calls) <= 12)
+	assert.True(t, atomic.LoadInt32(&calls) >= 11)
+}
+
+func TestWithHedgingParallelismLimiter(t *testing.T) {
+	var calls int32
+	ts := slowOddServer(&calls, make(chan struct{}, 1))
+	defer ts.Close()
+	c := NewClient(WithParallelismLimiter(false, &ParallelismLimiterOpinion{
+		LimiterMatcher: LimiterMatcher{Glob: "*"},
+		Parallelism:    1,
+	}), WithHedging(&HedgingOpinion{InitialDelay: 50 * time.Millisecond, MaxExtraLoad: 1}))
+	txt, err := Get(ts.URL).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", txt)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
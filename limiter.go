@@ -235,6 +235,21 @@ func WithParallelismLimiter(eachSite bool, opts ...*ParallelismLimiterOpinion) M
 	}
 	sites := sync.Map{}
 	return func(c *Client, h Handler) Handler {
+		c.saturationProbes = append(c.saturationProbes, func(req *Request) bool {
+			for i := range opts {
+				if opts[i].Match(req.URL) {
+					if !eachSite {
+						return atomic.LoadInt64(&opts[i].workingParallelism) >= opts[i].Parallelism
+					}
+					if v, ok := sites.Load(req.URL.Host); ok {
+						val := v.(*parallelismLimiterVal)
+						return atomic.LoadInt64(&val.workingParallelism) >= val.Parallelism
+					}
+					return false
+				}
+			}
+			return false
+		})
 		return func(req *Request) *Response {
 			if !eachSite {
 				for i := range opts {
@@ -0,0 +1,189 @@
+package goreq
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"strings"
+)
+
+// Link is a hyperlink of a page, from <a> or <area>.
+type Link struct {
+	URL  *url.URL
+	Text string
+	// Rel is the lower case tokens of rel attribute.
+	Rel []string
+	// NoFollow is true if rel has nofollow or the page has a robots meta tag of nofollow.
+	NoFollow bool
+	// Page is the URL of the page the link is on.
+	Page *url.URL
+}
+
+// Request returns a GET request to the link, with the page as Referer.
+func (s *Link) Request() *Request {
+	req := Get(s.URL.String())
+	if s.Page != nil {
+		req.AddHeader("Referer", s.Page.String())
+	}
+	return req
+}
+
+type AssetType string
+
+const (
+	ImageAsset      AssetType = "image"
+	ScriptAsset     AssetType = "script"
+	StylesheetAsset AssetType = "stylesheet"
+)
+
+// Asset is an image, a script or a stylesheet used by a page.
+type Asset struct {
+	URL  *url.URL
+	Type AssetType
+}
+
+// Request returns a GET request to the asset.
+func (s *Asset) Request() *Request {
+	return Get(s.URL.String())
+}
+
+// URL returns the final URL of the response, after redirects.
+func (s *Response) URL() *url.URL {
+	if s.Response != nil && s.Response.Request != nil {
+		return s.Response.Request.URL
+	}
+	if s.Req != nil {
+		return s.Req.URL
+	}
+	return nil
+}
+
+// baseURL returns the URL relative URLs of doc resolve against, by <base href> and
+// the final URL.
+func (s *Response) baseURL(doc *goquery.Document) *url.URL {
+	u := s.URL()
+	if u == nil {
+		u = &url.URL{}
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if b, err := u.Parse(strings.TrimSpace(href)); err == nil {
+			return b
+		}
+	}
+	return u
+}
+
+// resolveURL resolves ref against base, only http and https URLs are kept.
+func resolveURL(base *url.URL, ref string) *url.URL {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	return u
+}
+
+func relTokens(sel *goquery.Selection) []string {
+	return strings.Fields(strings.ToLower(sel.AttrOr("rel", "")))
+}
+
+// pageNoFollow reports whether robots meta tags of doc have nofollow or none.
+func pageNoFollow(doc *goquery.Document) bool {
+	nofollow := false
+	doc.Find("meta[name]").Each(func(_ int, sel *goquery.Selection) {
+		if !strings.EqualFold(sel.AttrOr("name", ""), "robots") {
+			return
+		}
+		for _, v := range strings.Split(strings.ToLower(sel.AttrOr("content", "")), ",") {
+			v = strings.TrimSpace(v)
+			if v == "nofollow" || v == "none" {
+				nofollow = true
+			}
+		}
+	})
+	return nofollow
+}
+
+// Links returns the http and https links of the page resolved against <base href> and
+// the final URL, in document order.
+func (s *Response) Links() ([]*Link, error) {
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	base, page := s.baseURL(doc), s.URL()
+	nofollow := pageNoFollow(doc)
+	var links []*Link
+	doc.Find("a[href], area[href]").Each(func(_ int, sel *goquery.Selection) {
+		u := resolveURL(base, sel.AttrOr("href", ""))
+		if u == nil {
+			return
+		}
+		rel := relTokens(sel)
+		links = append(links, &Link{
+			URL:      u,
+			Text:     strings.Join(strings.Fields(sel.Text()), " "),
+			Rel:      rel,
+			NoFollow: nofollow || containsString(rel, "nofollow"),
+			Page:     page,
+		})
+	})
+	return links, nil
+}
+
+// Assets returns images, scripts and stylesheets of the page resolved like Links. Every
+// candidate of srcset is returned as an image.
+func (s *Response) Assets() ([]*Asset, error) {
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	base := s.baseURL(doc)
+	var assets []*Asset
+	add := func(ref string, t AssetType) {
+		if u := resolveURL(base, ref); u != nil {
+			assets = append(assets, &Asset{URL: u, Type: t})
+		}
+	}
+	doc.Find("img, script[src], link[href]").Each(func(_ int, sel *goquery.Selection) {
+		switch goquery.NodeName(sel) {
+		case "img":
+			add(sel.AttrOr("src", ""), ImageAsset)
+			for _, c := range strings.Split(sel.AttrOr("srcset", ""), ",") {
+				if f := strings.Fields(c); len(f) > 0 {
+					add(f[0], ImageAsset)
+				}
+			}
+		case "script":
+			add(sel.AttrOr("src", ""), ScriptAsset)
+		case "link":
+			if containsString(relTokens(sel), "stylesheet") {
+				add(sel.AttrOr("href", ""), StylesheetAsset)
+			}
+		}
+	})
+	return assets, nil
+}
+
+// Canonical returns the URL of <link rel="canonical">, or the final URL if the page
+// doesn't have one.
+func (s *Response) Canonical() (*url.URL, error) {
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	base := s.baseURL(doc)
+	var canonical *url.URL
+	doc.Find("link[href]").EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		if containsString(relTokens(sel), "canonical") {
+			canonical = resolveURL(base, sel.AttrOr("href", ""))
+		}
+		return canonical == nil
+	})
+	if canonical == nil {
+		canonical = s.URL()
+	}
+	return canonical, nil
+}
@@ -0,0 +1,99 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const linksTestHTML = `<html><head>
+<base href="/static/">
+<link rel="Canonical" href="https://example.com/page">
+<link rel="stylesheet" href="a.css"><link rel="icon" href="i.ico">
+<script src="//cdn.example.com/a.js"></script><script>var a</script>
+</head><body>
+<a href="b.html">B
+	page</a>
+<a href="/c" rel="nofollow noopener">C</a>
+<a href="https://other.com/d">D</a>
+<a href="javascript:void(0)">js</a><a href="mailto:a@b.com">mail</a><a>none</a>
+<map><area href="e.html"></map>
+<img src="p.png" srcset="p1.png 1x, p2.png 2x">
+<form action="login" method="post" enctype="multipart/form-data"></form>
+<form></form>
+</body></html>`
+
+func TestResponse_Links(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/dir/page", http.StatusFound)
+		case "/dir/page":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, linksTestHTML)
+		case "/robots":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, `<meta name="ROBOTS" content="noindex, nofollow"><a href="x">x</a>`)
+		default:
+			_, _ = fmt.Fprint(w, r.Header.Get("Referer"))
+		}
+	}))
+	defer ts.Close()
+
+	resp := Get(ts.URL + "/old").Do()
+	assert.Equal(t, ts.URL+"/dir/page", resp.URL().String())
+
+	links, err := resp.Links()
+	assert.NoError(t, err)
+	assert.Len(t, links, 4)
+	assert.Equal(t, ts.URL+"/static/b.html", links[0].URL.String())
+	assert.Equal(t, "B page", links[0].Text)
+	assert.False(t, links[0].NoFollow)
+	assert.Equal(t, ts.URL+"/c", links[1].URL.String())
+	assert.Equal(t, []string{"nofollow", "noopener"}, links[1].Rel)
+	assert.True(t, links[1].NoFollow)
+	assert.Equal(t, "https://other.com/d", links[2].URL.String())
+	assert.Equal(t, ts.URL+"/static/e.html", links[3].URL.String())
+
+	txt, err := links[1].Request().Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.URL+"/dir/page", txt)
+
+	assets, err := resp.Assets()
+	assert.NoError(t, err)
+	var got []string
+	for _, a := range assets {
+		got = append(got, string(a.Type)+" "+a.URL.String())
+	}
+	assert.Equal(t, []string{
+		"stylesheet " + ts.URL + "/static/a.css",
+		"script http://cdn.example.com/a.js",
+		"image " + ts.URL + "/static/p.png",
+		"image " + ts.URL + "/static/p1.png",
+		"image " + ts.URL + "/static/p2.png",
+	}, got)
+
+	c, err := resp.Canonical()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/page", c.String())
+
+	forms, err := resp.Forms()
+	assert.NoError(t, err)
+	assert.Len(t, forms, 2)
+	assert.Equal(t, ts.URL+"/static/login", forms[0].Action.String())
+	assert.Equal(t, http.MethodPost, forms[0].Method)
+	assert.Equal(t, "multipart/form-data", forms[0].Enctype)
+	assert.Equal(t, ts.URL+"/dir/page", forms[1].Action.String())
+	assert.Equal(t, http.MethodGet, forms[1].Method)
+	assert.Equal(t, "application/x-www-form-urlencoded", forms[1].Enctype)
+
+	robots := Get(ts.URL + "/robots").Do()
+	links, err = robots.Links()
+	assert.NoError(t, err)
+	assert.True(t, links[0].NoFollow)
+	c, err = robots.Canonical()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.URL+"/robots", c.String())
+}
@@ -0,0 +1,186 @@
+package goreq
+
+import (
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"strings"
+)
+
+// Metadata is the structured metadata of a page.
+type Metadata struct {
+	// Title is the <title>, or og:title if the page has no title.
+	Title string
+	// Description is the description meta tag, or og:description.
+	Description string
+	// Canonical is the same as Response.Canonical.
+	Canonical *url.URL
+	// Language is the lang of <html>, the Content-Language meta tag or header.
+	Language string
+	// OpenGraph is og:* properties, like og:image, which can appear more than once.
+	OpenGraph map[string][]string
+	// Twitter is twitter:* card properties.
+	Twitter map[string]string
+	// Meta is the other meta tags with a name or property, in lower case.
+	Meta map[string]string
+	// JSONLD is the parsed <script type="application/ld+json"> blocks. Invalid blocks
+	// are skipped.
+	JSONLD []interface{}
+	// Microdata is the top level microdata items.
+	Microdata []*MicrodataItem
+}
+
+// MicrodataItem is an element with itemscope. A value of Properties is a string or a
+// nested *MicrodataItem.
+type MicrodataItem struct {
+	Type       []string
+	ID         string
+	Properties map[string][]interface{}
+}
+
+// Metadata extracts meta tags, OpenGraph and Twitter card properties, JSON-LD and
+// microdata from the HTML document.
+func (s *Response) Metadata() (*Metadata, error) {
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{
+		Title:     strings.TrimSpace(doc.Find("title").First().Text()),
+		OpenGraph: map[string][]string{},
+		Twitter:   map[string]string{},
+		Meta:      map[string]string{},
+	}
+	m.Canonical, _ = s.Canonical()
+
+	doc.Find("meta").Each(func(_ int, sel *goquery.Selection) {
+		content, ok := sel.Attr("content")
+		if !ok {
+			return
+		}
+		content = strings.TrimSpace(content)
+		if equiv, ok := sel.Attr("http-equiv"); ok {
+			if strings.EqualFold(equiv, "content-language") && m.Language == "" {
+				m.Language = content
+			}
+			return
+		}
+		key, ok := sel.Attr("property")
+		if !ok {
+			key, ok = sel.Attr("name")
+		}
+		if !ok {
+			return
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			m.OpenGraph[key] = append(m.OpenGraph[key], content)
+		case strings.HasPrefix(key, "twitter:"):
+			if _, ok := m.Twitter[key]; !ok {
+				m.Twitter[key] = content
+			}
+		default:
+			if _, ok := m.Meta[key]; !ok {
+				m.Meta[key] = content
+			}
+		}
+	})
+	m.Description = m.Meta["description"]
+	if m.Title == "" && len(m.OpenGraph["og:title"]) > 0 {
+		m.Title = m.OpenGraph["og:title"][0]
+	}
+	if m.Description == "" && len(m.OpenGraph["og:description"]) > 0 {
+		m.Description = m.OpenGraph["og:description"][0]
+	}
+	if lang := strings.TrimSpace(doc.Find("html").AttrOr("lang", "")); lang != "" {
+		m.Language = lang
+	}
+	if m.Language == "" && s.Response != nil {
+		m.Language = s.Header.Get("Content-Language")
+	}
+
+	doc.Find(`script[type]`).Each(func(_ int, sel *goquery.Selection) {
+		if !strings.EqualFold(strings.TrimSpace(sel.AttrOr("type", "")), "application/ld+json") {
+			return
+		}
+		var v interface{}
+		if json.Unmarshal([]byte(cleanJSONLD(sel.Text())), &v) == nil {
+			m.JSONLD = append(m.JSONLD, v)
+		}
+	})
+
+	base := s.baseURL(doc)
+	doc.Find("[itemscope]").Not("[itemprop]").Each(func(_ int, sel *goquery.Selection) {
+		m.Microdata = append(m.Microdata, microdataItem(sel, base))
+	})
+	return m, nil
+}
+
+// cleanJSONLD removes the HTML comment or CDATA wrapper some pages put in a script.
+func cleanJSONLD(s string) string {
+	s = strings.TrimSpace(s)
+	for _, w := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"//<![CDATA[", "//]]>"}} {
+		if strings.HasPrefix(s, w[0]) && strings.HasSuffix(s, w[1]) {
+			s = strings.TrimSpace(s[len(w[0]) : len(s)-len(w[1])])
+		}
+	}
+	return s
+}
+
+func microdataItem(sel *goquery.Selection, base *url.URL) *MicrodataItem {
+	item := &MicrodataItem{
+		Type:       strings.Fields(sel.AttrOr("itemtype", "")),
+		ID:         strings.TrimSpace(sel.AttrOr("itemid", "")),
+		Properties: map[string][]interface{}{},
+	}
+	var walk func(*goquery.Selection)
+	walk = func(parent *goquery.Selection) {
+		parent.Children().Each(func(_ int, c *goquery.Selection) {
+			if props, ok := c.Attr("itemprop"); ok {
+				v := microdataValue(c, base)
+				for _, p := range strings.Fields(props) {
+					item.Properties[p] = append(item.Properties[p], v)
+				}
+			}
+			if _, scope := c.Attr("itemscope"); !scope {
+				walk(c)
+			}
+		})
+	}
+	walk(sel)
+	return item
+}
+
+// microdataValue returns the value of a property element by the rules of the HTML spec.
+func microdataValue(sel *goquery.Selection, base *url.URL) interface{} {
+	if _, scope := sel.Attr("itemscope"); scope {
+		return microdataItem(sel, base)
+	}
+	attr := func(name string) string {
+		return strings.TrimSpace(sel.AttrOr(name, ""))
+	}
+	resolve := func(name string) string {
+		if u, err := base.Parse(attr(name)); err == nil && attr(name) != "" {
+			return u.String()
+		}
+		return attr(name)
+	}
+	switch goquery.NodeName(sel) {
+	case "meta":
+		return attr("content")
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return resolve("src")
+	case "a", "area", "link":
+		return resolve("href")
+	case "object":
+		return resolve("data")
+	case "data", "meter":
+		return attr("value")
+	case "time":
+		if v, ok := sel.Attr("datetime"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return strings.Join(strings.Fields(sel.Text()), " ")
+}
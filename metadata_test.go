@@ -0,0 +1,84 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const metadataTestHTML = `<html lang="en-US"><head>
+<title> Best Recipe </title>
+<meta name="Description" content="How to cook.">
+<meta name="keywords" content="food, cook">
+<meta property="og:title" content="OG Recipe">
+<meta property="og:image" content="https://example.com/1.jpg">
+<meta property="og:image" content="https://example.com/2.jpg">
+<meta name="twitter:card" content="summary">
+<link rel="canonical" href="/recipe">
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Recipe", "name": "Cake"}</script>
+<script type="application/ld+json"><!-- [{"@type": "Person"}] --></script>
+<script type="application/ld+json">{broken</script>
+</head><body>
+<div itemscope itemtype="https://schema.org/Product" itemid="urn:p1">
+	<h1 itemprop="name">Pan</h1>
+	<img itemprop="image" src="pan.jpg">
+	<a itemprop="url sameAs" href="/pan">link</a>
+	<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+		<meta itemprop="priceCurrency" content="USD"><data itemprop="price" value="9.5">$9.5</data>
+		<time itemprop="validFrom" datetime="2020-01-01">Jan</time>
+	</div>
+	<span><span itemprop="brand">Acme  Co</span></span>
+</div>
+<div itemscope><span itemprop="n">second</span></div>
+</body></html>`
+
+func TestResponse_Metadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/bare" {
+			w.Header().Set("Content-Language", "zh-CN")
+			_, _ = fmt.Fprint(w, `<meta property="og:title" content="Only OG"><meta property="og:description" content="D">`)
+			return
+		}
+		_, _ = fmt.Fprint(w, metadataTestHTML)
+	}))
+	defer ts.Close()
+
+	m, err := Get(ts.URL + "/page").Do().Metadata()
+	assert.NoError(t, err)
+	assert.Equal(t, "Best Recipe", m.Title)
+	assert.Equal(t, "How to cook.", m.Description)
+	assert.Equal(t, ts.URL+"/recipe", m.Canonical.String())
+	assert.Equal(t, "en-US", m.Language)
+	assert.Equal(t, []string{"https://example.com/1.jpg", "https://example.com/2.jpg"}, m.OpenGraph["og:image"])
+	assert.Equal(t, "summary", m.Twitter["twitter:card"])
+	assert.Equal(t, "food, cook", m.Meta["keywords"])
+
+	assert.Len(t, m.JSONLD, 2)
+	assert.Equal(t, "Cake", m.JSONLD[0].(map[string]interface{})["name"])
+	assert.Equal(t, "Person", m.JSONLD[1].([]interface{})[0].(map[string]interface{})["@type"])
+
+	assert.Len(t, m.Microdata, 2)
+	p := m.Microdata[0]
+	assert.Equal(t, []string{"https://schema.org/Product"}, p.Type)
+	assert.Equal(t, "urn:p1", p.ID)
+	assert.Equal(t, []interface{}{"Pan"}, p.Properties["name"])
+	assert.Equal(t, []interface{}{ts.URL + "/pan.jpg"}, p.Properties["image"])
+	assert.Equal(t, []interface{}{ts.URL + "/pan"}, p.Properties["sameAs"])
+	assert.Equal(t, []interface{}{"Acme Co"}, p.Properties["brand"])
+	offer := p.Properties["offers"][0].(*MicrodataItem)
+	assert.Equal(t, []interface{}{"USD"}, offer.Properties["priceCurrency"])
+	assert.Equal(t, []interface{}{"9.5"}, offer.Properties["price"])
+	assert.Equal(t, []interface{}{"2020-01-01"}, offer.Properties["validFrom"])
+	assert.Nil(t, p.Properties["price"])
+	assert.Equal(t, []interface{}{"second"}, m.Microdata[1].Properties["n"])
+
+	m, err = Get(ts.URL + "/bare").Do().Metadata()
+	assert.NoError(t, err)
+	assert.Equal(t, "Only OG", m.Title)
+	assert.Equal(t, "D", m.Description)
+	assert.Equal(t, "zh-CN", m.Language)
+	assert.Equal(t, ts.URL+"/bare", m.Canonical.String())
+}
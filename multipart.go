@@ -0,0 +1,289 @@
+package goreq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+)
+
+type FormField struct {
+	Name, Value string
+}
+
+// FormFile is a file part of multipart body. The content of File is streamed
+// to the server while the request is sending, it won't be buffered in memory.
+type FormFile struct {
+	FieldName, FileName, ContentType string
+	File                             io.Reader
+	// Size is the length of File. When it's 0 goreq tries to detect it from File,
+	// set it to -1 if the length is unknown.
+	Size int64
+	// Header is extra MIME header of this part. It overrides the default
+	// Content-Disposition and Content-Type.
+	Header textproto.MIMEHeader
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// SetMultipartBody sets a multipart/form-data body made of FormField and FormFile.
+// The body is generated while sending through an io.Pipe. Content-Length is set
+// if the size of every file is known, otherwise the body is sent chunked.
+// The body can be resent (on redirect, retry or when GetRequestHash is called)
+// only if every file is an io.Seeker.
+func (s *Request) SetMultipartBody(data ...interface{}) *Request {
+	if s.Err != nil {
+		if s.Debug {
+			log.Println("request has error", s.Err)
+		}
+		return s
+	}
+	m := &multipartBody{
+		boundary: multipart.NewWriter(nil).Boundary(),
+		seekable: true,
+	}
+	for _, v := range data {
+		switch v := v.(type) {
+		case FormField:
+			m.parts = append(m.parts, &multipartPart{field: v})
+		case FormFile:
+			p := &multipartPart{file: v, size: v.Size, offset: -1}
+			if p.size == 0 {
+				p.size = readerSize(v.File)
+			}
+			if sk, ok := v.File.(io.Seeker); ok {
+				if off, err := sk.Seek(0, io.SeekCurrent); err == nil {
+					p.offset = off
+				}
+			}
+			if p.offset < 0 {
+				m.seekable = false
+			}
+			m.parts = append(m.parts, p)
+		default:
+			s.Err = fmt.Errorf("unsupported multipart data type %T", v)
+			if s.Debug {
+				log.Println("set multipart body error", s.Err)
+			}
+			return s
+		}
+	}
+
+	s.ContentLength, s.Err = m.length()
+	if s.Err != nil {
+		if s.Debug {
+			log.Println("set multipart body error", s.Err)
+		}
+		return s
+	}
+	s.Request.Body = &lazyReadCloser{open: m.open}
+	if m.seekable {
+		s.GetBody = m.open
+	} else {
+		s.GetBody = nil
+	}
+	s.Header.Set("Content-Type", "multipart/form-data; boundary="+m.boundary)
+	return s
+}
+
+type multipartPart struct {
+	field  FormField
+	file   FormFile
+	size   int64
+	offset int64
+}
+
+func (p *multipartPart) isFile() bool {
+	return p.file.File != nil
+}
+
+func (p *multipartPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	name := p.file.FileName
+	if name == "" {
+		name = p.file.FieldName
+	}
+	h.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(p.file.FieldName), escapeQuotes(name)))
+	if p.file.ContentType != "" {
+		h.Set("Content-Type", p.file.ContentType)
+	} else {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	for k, v := range p.file.Header {
+		h[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	return h
+}
+
+type multipartBody struct {
+	parts    []*multipartPart
+	boundary string
+	seekable bool
+	lock     sync.Mutex
+}
+
+// length returns the size of the whole body, or -1 if any file size is unknown.
+func (m *multipartBody) length() (int64, error) {
+	cw := &countWriter{}
+	total := int64(0)
+	err := m.write(cw, func(p *multipartPart, w io.Writer) error {
+		if p.size < 0 {
+			total = -1
+		} else if total >= 0 {
+			total += p.size
+		}
+		return nil
+	})
+	if err != nil || total < 0 {
+		return -1, err
+	}
+	return total + cw.n, nil
+}
+
+func (m *multipartBody) open() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		pw.CloseWithError(m.write(pw, m.copyFile))
+	}()
+	return pr, nil
+}
+
+func (m *multipartBody) copyFile(p *multipartPart, w io.Writer) error {
+	r := p.file.File
+	if p.offset >= 0 {
+		if _, err := r.(io.Seeker).Seek(p.offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	if p.size < 0 {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	n, err := io.Copy(w, io.LimitReader(r, p.size))
+	if err != nil {
+		return err
+	}
+	if n != p.size {
+		return fmt.Errorf("multipart file %q is %d bytes, expected %d", p.file.FieldName, n, p.size)
+	}
+	return nil
+}
+
+func (m *multipartBody) write(dst io.Writer, file func(*multipartPart, io.Writer) error) error {
+	wr := multipart.NewWriter(dst)
+	if err := wr.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		if !p.isFile() {
+			if err := wr.WriteField(p.field.Name, p.field.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		w, err := wr.CreatePart(p.header())
+		if err != nil {
+			return err
+		}
+		if err = file(p, w); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
+
+// readerSize returns the remaining length of r, or -1 if it can't be known without reading.
+func readerSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case nil:
+		return -1
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		st, err := v.Stat()
+		if err != nil || !st.Mode().IsRegular() {
+			return -1
+		}
+		cur, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return st.Size() - cur
+	case io.Seeker:
+		cur, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := v.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err = v.Seek(cur, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - cur
+	}
+	return -1
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+var errBodyClosed = errors.New("body is closed")
+
+// lazyReadCloser opens the underlying reader on the first Read, so nothing is
+// generated for a request that is never sent.
+type lazyReadCloser struct {
+	open   func() (io.ReadCloser, error)
+	rc     io.ReadCloser
+	closed bool
+	lock   sync.Mutex
+}
+
+func (l *lazyReadCloser) Read(p []byte) (int, error) {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return 0, errBodyClosed
+	}
+	if l.rc == nil {
+		rc, err := l.open()
+		if err != nil {
+			l.lock.Unlock()
+			return 0, err
+		}
+		l.rc = rc
+	}
+	rc := l.rc
+	l.lock.Unlock()
+	return rc.Read(p)
+}
+
+func (l *lazyReadCloser) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closed = true
+	if l.rc != nil {
+		return l.rc.Close()
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -37,7 +38,7 @@ func WithDebug() Middleware {
 func WithCache(ca *cache.Cache) Middleware {
 	return func(x *Client, h Handler) Handler {
 		return func(req *Request) *Response {
-			if req.Context().Value(ctxNoCache) != nil {
+			if req.Context().Value(ctxNoCache) != nil || isStream(req) {
 				resp := h(req)
 				return resp
 			}
@@ -45,9 +46,14 @@ func WithCache(ca *cache.Cache) Middleware {
 			hash := GetRequestHash(req)
 
 			if data, ok := ca.Get(hash); ok {
-				resp := *data.(*Response)
+				cached := data.(*Response)
+				// the response may be cached by a request with larger limits
+				if err := x.sizeLimit(req).check(cached); err != nil {
+					return &Response{Response: cached.Response, Req: req, CacheHash: hash, Err: err}
+				}
+				resp := cached.copy()
 				resp.CacheHash = hash
-				return &resp
+				return resp
 			}
 
 			resp := h(req)
@@ -57,7 +63,8 @@ func WithCache(ca *cache.Cache) Middleware {
 				if s, ok := req.Context().Value(ctxCacheExpiration).(time.Duration); ok {
 					e = s
 				}
-				ca.Set(hash, resp, e)
+				// the cache keeps its own copy, so documents parsed by the caller are not shared
+				ca.Set(hash, resp.copy(), e)
 			}
 			return resp
 		}
@@ -90,8 +97,11 @@ func WithRetry(maxTimes int, isRespOk func(*Response) bool) Middleware {
 	}
 }
 
+type ctxRandomProxyType struct{}
+
+var ctxRandomProxy = &ctxRandomProxyType{}
+
 func WithProxy(p ...string) Middleware {
-	var RandSrc int64
 	var httpProxy = ""
 	var httpsProxy = ""
 	if e := os.Getenv("all_proxy"); e != "" {
@@ -102,24 +112,30 @@ func WithProxy(p ...string) Middleware {
 	} else if e := os.Getenv("http_proxy"); e != "" {
 		httpProxy = e
 	}
+	var lock sync.Mutex
+	ra := rand.New(rand.NewSource(time.Now().UnixNano()))
 	return func(x *Client, h Handler) Handler {
 		return func(req *Request) *Response {
-			if req.Context().Value("Proxy") == nil {
+			// a proxy set by WithProxy before, like for a retried request, is picked again
+			if req.Context().Value(ctxProxy) == nil || req.Context().Value(ctxRandomProxy) != nil {
+				proxy := ""
 				if len(p) > 1 {
-					RandSrc += 1
-					rs := rand.NewSource(time.Now().Unix() + RandSrc)
-					ra := rand.New(rs)
-					RandSrc = ra.Int63()
-					req.SetProxy(p[ra.Intn(len(p))])
+					lock.Lock()
+					i := ra.Intn(len(p))
+					lock.Unlock()
+					proxy = p[i]
 				} else if len(p) == 1 {
-					req.SetProxy(p[0])
+					proxy = p[0]
 				} else {
 					if req.URL.Scheme == "http" && httpProxy != "" {
-						req.SetProxy(httpProxy)
-					} else if req.URL.Scheme == "https" && httpProxy != "" {
-						req.SetProxy(httpsProxy)
+						proxy = httpProxy
+					} else if req.URL.Scheme == "https" && httpsProxy != "" {
+						proxy = httpsProxy
 					}
 				}
+				if proxy != "" {
+					req.SetProxy(proxy).addContextValue(ctxRandomProxy, proxy)
+				}
 			}
 			res := h(req)
 			return res
@@ -0,0 +1,158 @@
+package goreq
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/html/charset"
+	"gopkg.in/xmlpath.v2"
+	"strings"
+	"sync"
+)
+
+// NotJSONErr is returned by Response.JSON when the body isn't valid JSON.
+var NotJSONErr = errors.New("body is not valid json")
+
+// parsedBody keeps the documents parsed from body, so a Response parses each kind of
+// document only once however many times it's asked for.
+type parsedBody struct {
+	body []byte
+	// decoded tells body is decoded to UTF-8 from the encoding of response.
+	decoded bool
+	// lock guards results against share, readers after a Once don't need it.
+	lock sync.Mutex
+
+	htmlOnce sync.Once
+	htmlFrom *goquery.Document
+	htmlDoc  *goquery.Document
+	htmlErr  error
+
+	xmlOnce sync.Once
+	xmlNode *xmlpath.Node
+	xmlErr  error
+
+	jsonOnce sync.Once
+	jsonRes  gjson.Result
+	jsonErr  error
+
+	xmlQueryOnce sync.Once
+	xmlQueryNode *xmlquery.Node
+	xmlQueryErr  error
+}
+
+func sameBytes(a, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// parsed returns the parsed documents of the body. They're dropped if Body is replaced,
+// like by decoding.
+func (s *Response) parsed() *parsedBody {
+	m := s.mutex()
+	m.Lock()
+	defer m.Unlock()
+	decoded := s.decoded && s.Encoding != ""
+	if s.parsedBody == nil || !sameBytes(s.parsedBody.body, s.Body) || s.parsedBody.decoded != decoded {
+		s.parsedBody = &parsedBody{body: s.Body, decoded: decoded}
+	}
+	return s.parsedBody
+}
+
+// share returns parsedBody for a copy of the response, like one served by WithCache.
+// Immutable results are shared. The HTML document can be modified by goquery, so a
+// snapshot of it is taken, and every copy clones the snapshot on first use.
+func (s *parsedBody) share() *parsedBody {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p := &parsedBody{body: s.body, decoded: s.decoded, htmlFrom: s.htmlFrom}
+	if s.htmlDoc != nil {
+		p.htmlFrom = goquery.CloneDocument(s.htmlDoc)
+	}
+	if s.xmlNode != nil || s.xmlErr != nil {
+		p.xmlOnce.Do(func() {})
+		p.xmlNode, p.xmlErr = s.xmlNode, s.xmlErr
+	}
+	if s.jsonRes.Exists() || s.jsonErr != nil {
+		p.jsonOnce.Do(func() {})
+		p.jsonRes, p.jsonErr = s.jsonRes, s.jsonErr
+	}
+	return p
+}
+
+func (s *parsedBody) html() (*goquery.Document, error) {
+	s.htmlOnce.Do(func() {
+		var doc *goquery.Document
+		var err error
+		if s.htmlFrom != nil {
+			doc = goquery.CloneDocument(s.htmlFrom)
+		} else {
+			doc, err = goquery.NewDocumentFromReader(bytes.NewReader(s.body))
+		}
+		s.lock.Lock()
+		s.htmlDoc, s.htmlErr = doc, err
+		s.lock.Unlock()
+	})
+	return s.htmlDoc, s.htmlErr
+}
+
+func (s *parsedBody) xml() (*xmlpath.Node, error) {
+	s.xmlOnce.Do(func() {
+		n, err := xmlpath.ParseDecoder(s.xmlDecoder())
+		s.lock.Lock()
+		s.xmlNode, s.xmlErr = n, err
+		s.lock.Unlock()
+	})
+	return s.xmlNode, s.xmlErr
+}
+
+func (s *parsedBody) json(text string) (gjson.Result, error) {
+	s.jsonOnce.Do(func() {
+		if text == "" {
+			text = string(s.body)
+		}
+		res, err := gjson.Result{}, NotJSONErr
+		if gjson.Valid(text) {
+			res, err = gjson.Parse(text), nil
+		}
+		s.lock.Lock()
+		s.jsonRes, s.jsonErr = res, err
+		s.lock.Unlock()
+	})
+	return s.jsonRes, s.jsonErr
+}
+
+func (s *parsedBody) xmlQuery() (*xmlquery.Node, error) {
+	s.xmlQueryOnce.Do(func() {
+		n, err := xmlquery.Parse(bytes.NewReader(s.xmlBody()))
+		s.lock.Lock()
+		s.xmlQueryNode, s.xmlQueryErr = n, err
+		s.lock.Unlock()
+	})
+	return s.xmlQueryNode, s.xmlQueryErr
+}
+
+// xmlBody returns the body for XML parsers. A decoded body still declares its encoding
+// before decoding by <?xml ?>, so the declaration is changed to UTF-8 in a copy.
+func (s *parsedBody) xmlBody() []byte {
+	if !s.decoded {
+		return s.body
+	}
+	m := xmlEncodingRe.FindSubmatchIndex(s.body)
+	if m == nil || strings.EqualFold(string(s.body[m[2]:m[3]]), "utf-8") {
+		return s.body
+	}
+	b := make([]byte, 0, len(s.body))
+	b = append(b, s.body[:m[2]]...)
+	b = append(b, "UTF-8"...)
+	return append(b, s.body[m[3]:]...)
+}
+
+// xmlDecoder returns a decoder of xmlBody, which decodes the encoding declared by a body
+// not decoded.
+func (s *parsedBody) xmlDecoder() *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(s.xmlBody()))
+	d.CharsetReader = charset.NewReaderLabel
+	return d
+}
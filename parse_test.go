@@ -0,0 +1,91 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponse_ParseOnce(t *testing.T) {
+	resp := &Response{Req: Get("http://example.com/"), Body: []byte(`<p>a</p>`)}
+	var wg sync.WaitGroup
+	docs := make([]interface{}, 10)
+	for i := range docs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h, err := resp.HTML()
+			assert.NoError(t, err)
+			docs[i] = h
+		}(i)
+	}
+	wg.Wait()
+	for i := range docs {
+		assert.Same(t, docs[0], docs[i])
+	}
+
+	// a new body is parsed again
+	resp.Body = []byte(`<p>b</p>`)
+	h, _ := resp.HTML()
+	assert.NotSame(t, docs[0], h)
+	assert.Equal(t, "b", h.Find("p").Text())
+
+	_, err := resp.JSON()
+	assert.Equal(t, NotJSONErr, err)
+	resp = &Response{Body: []byte(`{"a":1}`), Text: `{"a":1}`}
+	j, err := resp.JSON()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), j.Get("a").Int())
+	x1, _ := resp.XML()
+	x2, _ := resp.XML()
+	assert.Same(t, x1, x2)
+
+	// each response has its own lock
+	other := &Response{Body: []byte(`<p>c</p>`)}
+	m := resp.mutex()
+	m.Lock()
+	h, err = other.HTML()
+	m.Unlock()
+	assert.NoError(t, err)
+	assert.Equal(t, "c", h.Find("p").Text())
+	assert.NotSame(t, m, resp.copy().mutex())
+}
+
+func TestWithCacheParsed(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<p class="a">a</p><p class="b">b</p>`)
+	}))
+	defer ts.Close()
+	c := NewClient(WithCache(cache.New(time.Minute, time.Minute)))
+
+	first := Get(ts.URL).SetClient(c).Do()
+	h1, err := first.HTML()
+	assert.NoError(t, err)
+	h1.Find(".a").Remove()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := Get(ts.URL).SetClient(c).Do()
+			h, err := resp.HTML()
+			assert.NoError(t, err)
+			assert.NotSame(t, h1, h)
+			// changes of other responses are not seen
+			assert.Equal(t, 1, h.Find(".a").Length())
+			h.Find(".b").Remove()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,374 @@
+package goreq
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ProxyDialer dials connections through a proxy. The scheme of URL decides the protocol:
+//
+//	socks4   SOCKS4, the hostname is resolved locally
+//	socks4a  SOCKS4a, the hostname is resolved by proxy
+//	socks5   SOCKS5, the hostname is resolved locally
+//	socks5h  SOCKS5, the hostname is resolved by proxy
+//	http     HTTP CONNECT
+//	https    HTTP CONNECT over TLS
+//
+// Username and password in URL are used to authenticate, the username is sent as the user id of SOCKS4.
+type ProxyDialer struct {
+	URL *url.URL
+	// Dialer dials the proxy server, a net.Dialer by default.
+	Dialer interface {
+		DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	}
+	// TLSConfig is used to connect https proxies.
+	TLSConfig *tls.Config
+	// Resolver resolves hostnames for socks4 and socks5, net.DefaultResolver by default.
+	Resolver *net.Resolver
+}
+
+func NewProxyDialer(proxy string) (*ProxyDialer, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "socks4", "socks4a", "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	return &ProxyDialer{
+		URL:    u,
+		Dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}, nil
+}
+
+// isDialerProxy reports whether the proxy has to be dialed by ProxyDialer, rather than
+// the Proxy of http.Transport.
+func isDialerProxy(u *url.URL) bool {
+	switch u.Scheme {
+	case "socks4", "socks4a", "socks5", "socks5h":
+		return true
+	}
+	return false
+}
+
+// DialContext connects to addr through the proxy. Only tcp networks are supported.
+func (d *ProxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("proxy doesn't support network %q", network)
+	}
+	proxyAddr := d.URL.Host
+	if d.URL.Port() == "" {
+		proxyAddr = net.JoinHostPort(d.URL.Hostname(), d.defaultPort())
+	}
+	conn, err := d.Dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if d.URL.Scheme == "https" {
+		cfg := d.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		cfg = cfg.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName = d.URL.Hostname()
+		}
+		tc := tls.Client(conn, cfg)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+
+	// interrupt the handshake when ctx is done
+	done := make(chan struct{})
+	interrupted := make(chan bool)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+			interrupted <- true
+		case <-done:
+			interrupted <- false
+		}
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	switch d.URL.Scheme {
+	case "socks4", "socks4a":
+		err = d.socks4(ctx, conn, addr)
+	case "socks5", "socks5h":
+		err = d.socks5(ctx, conn, addr)
+	default:
+		conn, err = d.connect(conn, addr)
+	}
+	close(done)
+	if <-interrupted {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *ProxyDialer) defaultPort() string {
+	switch d.URL.Scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return "1080"
+}
+
+func (d *ProxyDialer) resolve(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	r := d.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	ips, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip4 := ip.IP.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address of host %s", host)
+	}
+	return ips[0].IP, nil
+}
+
+func splitHostPort(addr string) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", port)
+	}
+	return host, uint16(p), nil
+}
+
+func (d *ProxyDialer) socks4(ctx context.Context, conn net.Conn, addr string) error {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	req := []byte{4, 1, byte(port >> 8), byte(port)}
+	var domain string
+	if ip := net.ParseIP(host); ip != nil || d.URL.Scheme == "socks4" {
+		if ip == nil {
+			if ip, err = d.resolve(ctx, host); err != nil {
+				return err
+			}
+		}
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return errors.New("socks4 doesn't support IPv6")
+		}
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0, 0, 0, 1)
+		domain = host
+	}
+	req = append(req, d.URL.User.Username()...)
+	req = append(req, 0)
+	if domain != "" {
+		req = append(req, domain...)
+		req = append(req, 0)
+	}
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+	var resp [8]byte
+	if _, err = io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[1] != 0x5a {
+		return fmt.Errorf("socks4 proxy rejected the connection, code %#x", resp[1])
+	}
+	return nil
+}
+
+var socks5Errors = []string{
+	"",
+	"general failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+func (d *ProxyDialer) socks5(ctx context.Context, conn net.Conn, addr string) error {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	password, hasAuth := d.URL.User.Password()
+	username := d.URL.User.Username()
+	hasAuth = hasAuth || username != ""
+	if hasAuth {
+		_, err = conn.Write([]byte{5, 2, 0, 2})
+	} else {
+		_, err = conn.Write([]byte{5, 1, 0})
+	}
+	if err != nil {
+		return err
+	}
+	var method [2]byte
+	if _, err = io.ReadFull(conn, method[:]); err != nil {
+		return err
+	}
+	if method[0] != 5 {
+		return fmt.Errorf("unexpected socks version %d", method[0])
+	}
+	switch method[1] {
+	case 0:
+	case 2:
+		if !hasAuth {
+			return errors.New("socks5 proxy requires authentication")
+		}
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("socks5 username or password is too long")
+		}
+		auth := []byte{1, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err = conn.Write(auth); err != nil {
+			return err
+		}
+		var status [2]byte
+		if _, err = io.ReadFull(conn, status[:]); err != nil {
+			return err
+		}
+		if status[1] != 0 {
+			return errors.New("socks5 proxy authentication failed")
+		}
+	default:
+		return errors.New("socks5 proxy has no acceptable authentication method")
+	}
+
+	req := []byte{5, 1, 0}
+	ip := net.ParseIP(host)
+	if ip == nil && d.URL.Scheme == "socks5" {
+		if ip, err = d.resolve(ctx, host); err != nil {
+			return err
+		}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 1)
+		req = append(req, ip4...)
+	} else if ip != nil {
+		req = append(req, 4)
+		req = append(req, ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return errors.New("socks5 hostname is too long")
+		}
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+
+	var resp [4]byte
+	if _, err = io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[1] != 0 {
+		msg := "unknown error"
+		if int(resp[1]) < len(socks5Errors) {
+			msg = socks5Errors[resp[1]]
+		}
+		return fmt.Errorf("socks5 proxy failed to connect: %s", msg)
+	}
+	var skip int
+	switch resp[3] {
+	case 1:
+		skip = 4
+	case 4:
+		skip = 16
+	case 3:
+		var l [1]byte
+		if _, err = io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("socks5 proxy replied unknown address type %d", resp[3])
+	}
+	_, err = io.CopyN(io.Discard, conn, int64(skip+2))
+	return err
+}
+
+func (d *ProxyDialer) connect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := d.URL.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+
+			base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return conn, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,345 @@
+package goreq
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// socksServer is a minimal SOCKS4/4a/5 server recording the destinations it's asked for.
+type socksServer struct {
+	ln       net.Listener
+	user     string
+	password string
+
+	lock  sync.Mutex
+	dests []string
+}
+
+func newSocksServer(t *testing.T, user, password string) *socksServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socksServer{ln: ln, user: user, password: password}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *socksServer) URL(scheme string) string {
+	u := &url.URL{Scheme: scheme, Host: s.ln.Addr().String()}
+	if s.user != "" {
+		u.User = url.UserPassword(s.user, s.password)
+	}
+	return u.String()
+}
+
+func (s *socksServer) Close() {
+	_ = s.ln.Close()
+}
+
+func (s *socksServer) Dests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.dests...)
+}
+
+func readUntilZero(r *bufio.Reader) string {
+	b, _ := r.ReadBytes(0)
+	return strings.TrimSuffix(string(b), "\x00")
+}
+
+func (s *socksServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	ver, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	var dest string
+	switch ver {
+	case 4:
+		head := make([]byte, 7)
+		if _, err = io.ReadFull(r, head); err != nil {
+			return
+		}
+		port := binary.BigEndian.Uint16(head[1:3])
+		ip := net.IP(head[3:7])
+		user := readUntilZero(r)
+		host := ip.String()
+		if ip[0] == 0 && ip[1] == 0 && ip[2] == 0 && ip[3] != 0 {
+			host = readUntilZero(r)
+		}
+		if user != s.user {
+			_, _ = c.Write([]byte{0, 0x5b, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		dest = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	case 5:
+		n, _ := r.ReadByte()
+		methods := make([]byte, n)
+		_, _ = io.ReadFull(r, methods)
+		if s.user != "" {
+			_, _ = c.Write([]byte{5, 2})
+			_, _ = r.ReadByte()
+			ul, _ := r.ReadByte()
+			user := make([]byte, ul)
+			_, _ = io.ReadFull(r, user)
+			pl, _ := r.ReadByte()
+			pass := make([]byte, pl)
+			_, _ = io.ReadFull(r, pass)
+			if string(user) != s.user || string(pass) != s.password {
+				_, _ = c.Write([]byte{1, 1})
+				return
+			}
+			_, _ = c.Write([]byte{1, 0})
+		} else {
+			_, _ = c.Write([]byte{5, 0})
+		}
+		head := make([]byte, 4)
+		if _, err = io.ReadFull(r, head); err != nil {
+			return
+		}
+		var host string
+		switch head[3] {
+		case 1:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 4:
+			ip := make([]byte, 16)
+			_, _ = io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 3:
+			l, _ := r.ReadByte()
+			name := make([]byte, l)
+			_, _ = io.ReadFull(r, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		_, _ = io.ReadFull(r, port)
+		dest = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	default:
+		return
+	}
+	s.lock.Lock()
+	s.dests = append(s.dests, dest)
+	s.lock.Unlock()
+
+	target, err := net.Dial("tcp", dest)
+	if err != nil {
+		if ver == 4 {
+			_, _ = c.Write([]byte{0, 0x5b, 0, 0, 0, 0, 0, 0})
+		} else {
+			_, _ = c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		}
+		return
+	}
+	defer target.Close()
+	if ver == 4 {
+		_, _ = c.Write([]byte{0, 0x5a, 0, 0, 0, 0, 0, 0})
+	} else {
+		_, _ = c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	}
+	go func() {
+		_, _ = io.Copy(target, r)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(c, target)
+}
+
+func newConnectProxy(t *testing.T, auth string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if auth != "" && r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(target, brw)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+	}))
+}
+
+func localhostURL(ts *httptest.Server) string {
+	return strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+}
+
+func TestRequest_SetProxySocks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "hello")
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	cases := []struct {
+		scheme, user, password, dest string
+	}{
+		{"socks5", "", "", "127.0.0.1:" + port},
+		{"socks5h", "", "", "localhost:" + port},
+		{"socks5h", "user", "pass", "localhost:" + port},
+		{"socks4", "", "", "127.0.0.1:" + port},
+		{"socks4a", "id", "", "localhost:" + port},
+	}
+	c := NewClient()
+	for _, v := range cases {
+		s := newSocksServer(t, v.user, v.password)
+		txt, err := Get(localhostURL(ts)).SetProxy(s.URL(v.scheme)).SetClient(c).Do().Txt()
+		assert.NoError(t, err, v.scheme)
+		assert.Equal(t, "hello", txt, v.scheme)
+		assert.Equal(t, []string{v.dest}, s.Dests(), v.scheme)
+		s.Close()
+	}
+
+	s := newSocksServer(t, "user", "pass")
+	defer s.Close()
+	u, _ := url.Parse(s.URL("socks5"))
+	u.User = url.UserPassword("user", "wrong")
+	assert.Error(t, Get(ts.URL).SetProxy(u.String()).SetClient(c).Do().Err)
+	u.Scheme = "socks4"
+	u.User = url.User("other")
+	assert.Error(t, Get(ts.URL).SetProxy(u.String()).SetClient(c).Do().Err)
+}
+
+func TestRequest_SetProxySocksIsolation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "hello")
+	}))
+	defer ts.Close()
+	a, b := newSocksServer(t, "", ""), newSocksServer(t, "", "")
+	defer a.Close()
+	defer b.Close()
+	c := NewClient()
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, Get(ts.URL).SetProxy(a.URL("socks5")).SetClient(c).Do().Err)
+		assert.NoError(t, Get(ts.URL).SetProxy(b.URL("socks5")).SetClient(c).Do().Err)
+		assert.NoError(t, Get(ts.URL).SetClient(c).Do().Err)
+	}
+	// connections are kept alive in each proxy's own transport
+	assert.Len(t, a.Dests(), 1)
+	assert.Len(t, b.Dests(), 1)
+}
+
+func TestClient_TransportCache(t *testing.T) {
+	c := NewClient()
+	first, err := c.transport(nil, 1, false)
+	assert.NoError(t, err)
+	recent, _ := c.transport(nil, 2, false)
+	for i := 3; i <= maxTransports+10; i++ {
+		_, _ = c.transport(nil, int64(i), false)
+		// keep using one of them
+		_, _ = c.transport(nil, 2, false)
+	}
+	assert.Equal(t, maxTransports, c.transports.list.Len())
+	assert.Len(t, c.transports.items, maxTransports)
+	again, _ := c.transport(nil, 2, false)
+	assert.Same(t, recent, again)
+	again, _ = c.transport(nil, 1, false)
+	assert.NotSame(t, first, again)
+}
+
+func TestRequest_SetProxyConnectAuth(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "secure")
+	}))
+	defer ts.Close()
+	p := newConnectProxy(t, "Basic dXNlcjpwYXNz")
+	defer p.Close()
+	pu, _ := url.Parse(p.URL)
+
+	pu.User = url.UserPassword("user", "pass")
+	txt, err := Get(ts.URL).SetProxy(pu.String()).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "secure", txt)
+
+	pu.User = url.UserPassword("user", "wrong")
+	assert.Error(t, Get(ts.URL).SetProxy(pu.String()).Do().Err)
+}
+
+func TestProxyDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(c, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+	p := newConnectProxy(t, "Basic dXNlcjpwYXNz")
+	defer p.Close()
+	s := newSocksServer(t, "user", "pass")
+	defer s.Close()
+
+	for _, proxy := range []string{
+		strings.Replace(p.URL, "http://", "http://user:pass@", 1),
+		s.URL("socks5h"),
+	} {
+		d, err := NewProxyDialer(proxy)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		conn, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+		if !assert.NoError(t, err, proxy) {
+			continue
+		}
+		_, _ = conn.Write([]byte("echo"))
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "echo", string(buf))
+		_ = conn.Close()
+	}
+
+	_, err = NewProxyDialer("ftp://127.0.0.1")
+	assert.Error(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d, _ := NewProxyDialer(s.URL("socks5"))
+	_, err = d.DialContext(ctx, "tcp", ln.Addr().String())
+	assert.Error(t, err)
+}
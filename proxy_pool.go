@@ -0,0 +1,319 @@
+package goreq
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type ProxyStrategy uint8
+
+const (
+	RoundRobinProxy ProxyStrategy = iota
+	RandomProxy
+	LeastFailuresProxy
+)
+
+var NoProxyAvailableErr = errors.New("no proxy is available")
+
+type ProxyPoolOpinion struct {
+	Strategy ProxyStrategy
+	// MaxFailures is how many failures in a row quarantine a proxy, 3 by default.
+	MaxFailures int
+	// QuarantineTime is how long a failing proxy is skipped, 1 minute by default.
+	QuarantineTime time.Duration
+	// HealthCheckURL is requested through every proxy each HealthCheckInterval
+	// (30 seconds by default). Health checks are disabled if it's empty.
+	HealthCheckURL      string
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// IsRespOk tells whether a response through the proxy succeeded. By default
+	// any response without error is ok.
+	IsRespOk func(*Response) bool
+}
+
+// ProxyStat is a snapshot of the statistics of a proxy in ProxyPool.
+type ProxyStat struct {
+	Proxy               string
+	Requests            int64
+	Failures            int64
+	ConsecutiveFailures int
+	Quarantined         bool
+	QuarantinedUntil    time.Time
+	Latency             time.Duration
+	LastError           error
+	LastCheck           time.Time
+}
+
+type proxyEntry struct {
+	ProxyStat
+	url string
+}
+
+// ProxyPool picks a proxy for each request, tracks failures and quarantines failing
+// proxies. Use it by WithProxyPool.
+type ProxyPool struct {
+	opt       ProxyPoolOpinion
+	lock      sync.Mutex
+	proxies   []*proxyEntry
+	next      int
+	rand      *rand.Rand
+	stop      chan struct{}
+	client    *Client
+	closeOnce sync.Once
+}
+
+// NewProxyPool creates a pool of proxies. Health checks start if opt.HealthCheckURL
+// is set, stop them by Close.
+func NewProxyPool(opt *ProxyPoolOpinion, proxies ...string) (*ProxyPool, error) {
+	p := &ProxyPool{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+		stop: make(chan struct{}),
+	}
+	if opt != nil {
+		p.opt = *opt
+	}
+	if p.opt.MaxFailures <= 0 {
+		p.opt.MaxFailures = 3
+	}
+	if p.opt.QuarantineTime <= 0 {
+		p.opt.QuarantineTime = time.Minute
+	}
+	if p.opt.HealthCheckInterval <= 0 {
+		p.opt.HealthCheckInterval = 30 * time.Second
+	}
+	if p.opt.HealthCheckTimeout <= 0 {
+		p.opt.HealthCheckTimeout = 10 * time.Second
+	}
+	for _, proxy := range proxies {
+		if err := p.Add(proxy); err != nil {
+			return nil, err
+		}
+	}
+	if p.opt.HealthCheckURL != "" {
+		p.client = NewClient()
+		go p.healthCheck()
+	}
+	return p, nil
+}
+
+// Add adds a proxy to the pool.
+func (p *ProxyPool) Add(proxy string) error {
+	if _, err := url.Parse(proxy); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, e := range p.proxies {
+		if e.url == proxy {
+			return nil
+		}
+	}
+	p.proxies = append(p.proxies, &proxyEntry{ProxyStat: ProxyStat{Proxy: proxy}, url: proxy})
+	return nil
+}
+
+// Remove removes a proxy from the pool.
+func (p *ProxyPool) Remove(proxy string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, e := range p.proxies {
+		if e.url == proxy {
+			p.proxies = append(p.proxies[:i], p.proxies[i+1:]...)
+			return
+		}
+	}
+}
+
+// Pick returns a proxy which is not quarantined by the strategy of pool.
+func (p *ProxyPool) Pick() (string, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	var available []*proxyEntry
+	for _, e := range p.proxies {
+		if !e.quarantined(now) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		return "", false
+	}
+	var picked *proxyEntry
+	switch p.opt.Strategy {
+	case RandomProxy:
+		picked = available[p.rand.Intn(len(available))]
+	case LeastFailuresProxy:
+		for _, e := range available {
+			if picked == nil || e.Failures < picked.Failures ||
+				e.Failures == picked.Failures && e.Requests < picked.Requests {
+				picked = e
+			}
+		}
+	default:
+		picked = available[p.next%len(available)]
+		p.next = (p.next + 1) % len(available)
+	}
+	return picked.url, true
+}
+
+// Report records the result of a request through proxy, err is nil if it succeeded.
+func (p *ProxyPool) Report(proxy string, latency time.Duration, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, e := range p.proxies {
+		if e.url == proxy {
+			e.report(latency, err, p.opt)
+			return
+		}
+	}
+}
+
+// IsQuarantined reports whether proxy is skipped for now.
+func (p *ProxyPool) IsQuarantined(proxy string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, e := range p.proxies {
+		if e.url == proxy {
+			return e.quarantined(time.Now())
+		}
+	}
+	return false
+}
+
+// Stats returns the statistics of every proxy in the pool.
+func (p *ProxyPool) Stats() []ProxyStat {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	res := make([]ProxyStat, len(p.proxies))
+	for i, e := range p.proxies {
+		res[i] = e.ProxyStat
+		res[i].Quarantined = e.quarantined(now)
+	}
+	return res
+}
+
+// Close stops the health checks.
+func (p *ProxyPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+func (e *proxyEntry) quarantined(now time.Time) bool {
+	return now.Before(e.QuarantinedUntil)
+}
+
+func (e *proxyEntry) report(latency time.Duration, err error, opt ProxyPoolOpinion) {
+	e.Requests += 1
+	e.LastError = err
+	if err == nil {
+		e.ConsecutiveFailures = 0
+		e.QuarantinedUntil = time.Time{}
+		if e.Latency == 0 {
+			e.Latency = latency
+		} else {
+			e.Latency = (e.Latency*7 + latency) / 8
+		}
+		return
+	}
+	e.Failures += 1
+	e.ConsecutiveFailures += 1
+	if e.ConsecutiveFailures >= opt.MaxFailures {
+		e.QuarantinedUntil = time.Now().Add(opt.QuarantineTime)
+	}
+}
+
+var proxyRespNotOkErr = errors.New("response through proxy is not ok")
+
+func (p *ProxyPool) check(resp *Response) error {
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if p.opt.IsRespOk != nil && !p.opt.IsRespOk(resp) {
+		return proxyRespNotOkErr
+	}
+	return nil
+}
+
+func (p *ProxyPool) healthCheck() {
+	t := time.NewTicker(p.opt.HealthCheckInterval)
+	defer t.Stop()
+	for {
+		p.lock.Lock()
+		proxies := make([]string, len(p.proxies))
+		for i, e := range p.proxies {
+			proxies[i] = e.url
+		}
+		p.lock.Unlock()
+
+		var wg sync.WaitGroup
+		wg.Add(len(proxies))
+		for _, proxy := range proxies {
+			go func(proxy string) {
+				defer wg.Done()
+				start := time.Now()
+				req := Get(p.opt.HealthCheckURL).SetProxy(proxy).
+					SetTimeout(p.opt.HealthCheckTimeout).NoCache().SetClient(p.client)
+				err := p.check(req.Do())
+				req.cancel()
+				p.lock.Lock()
+				defer p.lock.Unlock()
+				for _, e := range p.proxies {
+					if e.url == proxy {
+						e.report(time.Since(start), err, p.opt)
+						e.LastCheck = time.Now()
+					}
+				}
+			}(proxy)
+		}
+		wg.Wait()
+
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type ctxPoolProxyType struct{}
+
+var ctxPoolProxy = &ctxPoolProxyType{}
+
+// hasUserProxy reports whether the proxy of req is set by SetProxy, rather than by a middleware.
+func hasUserProxy(req *Request) bool {
+	return req.Context().Value(ctxProxy) != nil && req.Context().Value(ctxPoolProxy) == nil &&
+		req.Context().Value(ctxSessionProxy) == nil && req.Context().Value(ctxRandomProxy) == nil
+}
+
+// WithProxyPool sends requests through proxies picked from pool. Requests which have
+// a proxy set by SetProxy are not changed. A retried request picks a new proxy.
+func WithProxyPool(pool *ProxyPool) Middleware {
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if hasUserProxy(req) || req.Context().Value(ctxSessionProxy) != nil {
+				return h(req)
+			}
+			proxy, ok := pool.Pick()
+			if !ok {
+				return &Response{Req: req, Err: NoProxyAvailableErr}
+			}
+			req.SetProxy(proxy).addContextValue(ctxPoolProxy, proxy)
+			start := time.Now()
+			res := h(req)
+			if res != nil {
+				err := pool.check(res)
+				if err != nil && req.Debug {
+					log.Println("request through proxy", proxy, "failed", err)
+				}
+				pool.Report(proxy, time.Since(start), err)
+			}
+			return res
+		}
+	}
+}
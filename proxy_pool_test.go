@@ -0,0 +1,126 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func namedProxy(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, name)
+	}))
+}
+
+func TestWithProxyPool(t *testing.T) {
+	a, b := namedProxy("a"), namedProxy("b")
+	defer a.Close()
+	defer b.Close()
+	dead := namedProxy("dead")
+	dead.Close()
+
+	pool, err := NewProxyPool(&ProxyPoolOpinion{MaxFailures: 2, QuarantineTime: time.Hour}, a.URL, b.URL, dead.URL)
+	assert.NoError(t, err)
+	defer pool.Close()
+	c := NewClient(WithProxyPool(pool))
+
+	got := map[string]int{}
+	failed := 0
+	for i := 0; i < 12; i++ {
+		txt, err := Get("http://example.com/").SetClient(c).Do().Txt()
+		if err != nil {
+			failed += 1
+			continue
+		}
+		got[txt] += 1
+	}
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, 5, got["a"])
+	assert.Equal(t, 5, got["b"])
+	assert.True(t, pool.IsQuarantined(dead.URL))
+
+	stats := pool.Stats()
+	assert.Equal(t, dead.URL, stats[2].Proxy)
+	assert.Equal(t, int64(2), stats[2].Failures)
+	assert.True(t, stats[2].Quarantined)
+	assert.Equal(t, int64(5), stats[0].Requests)
+	assert.Equal(t, int64(0), stats[0].Failures)
+
+	txt, err := Get("http://example.com/").SetProxy(a.URL).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", txt)
+	assert.Equal(t, int64(5), pool.Stats()[0].Requests)
+
+	pool.Remove(a.URL)
+	pool.Remove(b.URL)
+	assert.Equal(t, NoProxyAvailableErr, Get("http://example.com/").SetClient(c).Do().Err)
+}
+
+func TestWithProxyPoolRetry(t *testing.T) {
+	a := namedProxy("a")
+	defer a.Close()
+	dead := namedProxy("dead")
+	dead.Close()
+	pool, err := NewProxyPool(&ProxyPoolOpinion{Strategy: LeastFailuresProxy}, dead.URL, a.URL)
+	assert.NoError(t, err)
+	c := NewClient(WithProxyPool(pool), WithRetry(2, nil))
+	txt, err := Get("http://example.com/").SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", txt)
+	for i := 0; i < 5; i++ {
+		txt, _ = Get("http://example.com/").SetClient(c).Do().Txt()
+		assert.Equal(t, "a", txt)
+	}
+}
+
+func TestWithProxyRetry(t *testing.T) {
+	a := namedProxy("a")
+	defer a.Close()
+	dead := namedProxy("dead")
+	dead.Close()
+	c := NewClient(WithProxy(dead.URL, a.URL), WithRetry(20, nil))
+	for i := 0; i < 5; i++ {
+		// a retried request picks a proxy again, rather than keeping the dead one
+		txt, err := Get("http://example.com/").SetClient(c).Do().Txt()
+		assert.NoError(t, err)
+		assert.Equal(t, "a", txt)
+	}
+	txt, err := Get("http://example.com/").SetProxy(a.URL).SetClient(NewClient(WithProxy(dead.URL))).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", txt)
+}
+
+func TestProxyPool_HealthCheck(t *testing.T) {
+	var healthy int32
+	p := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer p.Close()
+	pool, err := NewProxyPool(&ProxyPoolOpinion{
+		MaxFailures:         1,
+		QuarantineTime:      time.Hour,
+		HealthCheckURL:      "http://example.com/",
+		HealthCheckInterval: 20 * time.Millisecond,
+		IsRespOk: func(resp *Response) bool {
+			return resp.StatusCode == http.StatusOK
+		},
+	}, p.URL)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, pool.IsQuarantined(p.URL))
+	_, ok := pool.Pick()
+	assert.False(t, ok)
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, pool.IsQuarantined(p.URL))
+	assert.False(t, pool.Stats()[0].LastCheck.IsZero())
+}
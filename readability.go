@@ -0,0 +1,348 @@
+package goreq
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Article is the main content of a page, extracted by Response.Article.
+type Article struct {
+	Title  string
+	Byline string
+	// Text is the plain text of the content, paragraphs are separated by a blank line.
+	Text string
+	// HTML is the content with only basic tags and href, src, alt and title attributes.
+	// URLs are absolute.
+	HTML string
+}
+
+var (
+	readabilityUnlikely = regexp.MustCompile(`(?i)-ad-|ad-break|agegate|banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|newsletter|pager|pagination|popup|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental`)
+	readabilityMaybe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	readabilityPositive = regexp.MustCompile(`(?i)article|blog|body|content|entry|hentry|h-entry|main|page|post|story|text`)
+	readabilityNegative = regexp.MustCompile(`(?i)-ad-|hidden|banner|combx|comment|com-|contact|foot|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	readabilityByline   = regexp.MustCompile(`(?i)byline|author|dateline|writtenby`)
+)
+
+// readabilityBlocks are tags that make a div not a paragraph.
+const readabilityBlocks = "blockquote dl div img ol p pre table ul section article h1 h2 h3 h4 h5 h6"
+
+// readabilityTags are tags kept in Article.HTML, others are replaced by their children.
+var readabilityTags = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "a": true, "img": true, "ul": true, "ol": true, "li": true, "dl": true,
+	"dt": true, "dd": true, "blockquote": true, "pre": true, "code": true, "em": true, "strong": true,
+	"b": true, "i": true, "u": true, "sub": true, "sup": true, "figure": true, "figcaption": true,
+	"table": true, "thead": true, "tbody": true, "tr": true, "th": true, "td": true,
+}
+
+// readabilityTextBlocks separate paragraphs in Article.Text.
+var readabilityTextBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"blockquote": true, "pre": true, "figure": true, "figcaption": true, "table": true, "tr": true,
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func classAndID(sel *goquery.Selection) string {
+	return sel.AttrOr("class", "") + " " + sel.AttrOr("id", "")
+}
+
+func classWeight(sel *goquery.Selection) float64 {
+	w := 0.0
+	for _, v := range []string{sel.AttrOr("class", ""), sel.AttrOr("id", "")} {
+		if v == "" {
+			continue
+		}
+		if readabilityNegative.MatchString(v) {
+			w -= 25
+		}
+		if readabilityPositive.MatchString(v) {
+			w += 25
+		}
+	}
+	return w
+}
+
+func tagScore(tag string) float64 {
+	switch tag {
+	case "div", "article":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	}
+	return 0
+}
+
+// linkDensity is the ratio of text in links to all text of sel.
+func linkDensity(sel *goquery.Selection) float64 {
+	n := utf8.RuneCountInString(normalizeSpace(sel.Text()))
+	if n == 0 {
+		return 0
+	}
+	l := 0
+	sel.Find("a").Each(func(_ int, a *goquery.Selection) {
+		l += utf8.RuneCountInString(normalizeSpace(a.Text()))
+	})
+	return float64(l) / float64(n)
+}
+
+func articleTitle(doc *goquery.Document) string {
+	title := normalizeSpace(doc.Find("title").First().Text())
+	if title == "" {
+		title = normalizeSpace(doc.Find(`meta[property="og:title"]`).AttrOr("content", ""))
+	}
+	if h1 := doc.Find("h1"); h1.Length() == 1 {
+		if h := normalizeSpace(h1.Text()); h != "" && (title == "" || strings.Contains(title, h)) {
+			return h
+		}
+	}
+	for _, sep := range []string{" | ", " - ", " – ", " — ", " :: ", " / ", " _ "} {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			if head := title[:i]; len(strings.Fields(head)) >= 3 || utf8.RuneCountInString(head) >= 10 {
+				return head
+			}
+		}
+	}
+	return title
+}
+
+func articleByline(doc *goquery.Document) string {
+	if author := normalizeSpace(doc.Find(`meta[name="author"]`).AttrOr("content", "")); author != "" {
+		return author
+	}
+	byline := ""
+	doc.Find("body *").EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		_, relAuthor := sel.Attr("rel")
+		relAuthor = relAuthor && containsString(relTokens(sel), "author")
+		itemAuthor := containsString(strings.Fields(sel.AttrOr("itemprop", "")), "author")
+		if relAuthor || itemAuthor || readabilityByline.MatchString(classAndID(sel)) {
+			if t := normalizeSpace(sel.Text()); t != "" && utf8.RuneCountInString(t) < 100 {
+				byline = t
+				return false
+			}
+		}
+		return true
+	})
+	return byline
+}
+
+// Article extracts the main content of the page like Readability: nodes are scored by
+// their paragraphs, class names and link density, and the best one with its related
+// siblings is returned as clean text and HTML. The document of HTML() is not modified.
+func (s *Response) Article() (*Article, error) {
+	doc, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	doc = goquery.CloneDocument(doc)
+	base := s.baseURL(doc)
+	a := &Article{Title: articleTitle(doc), Byline: articleByline(doc)}
+
+	doc.Find("script, style, noscript, iframe, svg, canvas, object, embed, template, link, meta, form, button, input, select, textarea, nav, aside, footer").Remove()
+	doc.Find("body *").Each(func(_ int, sel *goquery.Selection) {
+		if goquery.NodeName(sel) == "article" || sel.Closest("table, code, pre").Length() > 0 {
+			return
+		}
+		if ci := classAndID(sel); readabilityUnlikely.MatchString(ci) && !readabilityMaybe.MatchString(ci) {
+			sel.Remove()
+		}
+	})
+
+	scores := map[*html.Node]float64{}
+	var candidates []*goquery.Selection
+	doc.Find("p, pre, td, div").Each(func(_ int, sel *goquery.Selection) {
+		if goquery.NodeName(sel) == "div" && sel.ChildrenFiltered(strings.ReplaceAll(readabilityBlocks, " ", ", ")).Length() > 0 {
+			return
+		}
+		text := normalizeSpace(sel.Text())
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(length/100), 3)
+		for level, anc := 0, sel.Parent(); level < 3 && anc.Length() > 0; level, anc = level+1, anc.Parent() {
+			n := anc.Get(0)
+			if n.Type != html.ElementNode || n.Data == "html" {
+				break
+			}
+			if _, ok := scores[n]; !ok {
+				scores[n] = tagScore(n.Data) + classWeight(anc)
+				candidates = append(candidates, anc)
+			}
+			divider := []float64{1, 2, 6}[level]
+			scores[n] += score / divider
+		}
+	})
+
+	var top *goquery.Selection
+	topScore := 0.0
+	for _, c := range candidates {
+		n := c.Get(0)
+		scores[n] *= 1 - linkDensity(c)
+		if top == nil || scores[n] > topScore {
+			top, topScore = c, scores[n]
+		}
+	}
+	if top == nil {
+		top = doc.Find("body")
+	}
+
+	content := goquery.NewDocumentFromNode(&html.Node{Type: html.ElementNode, Data: "div"}).Selection
+	threshold := math.Max(10, topScore*0.2)
+	siblings := top.Parent().Children()
+	if top.Parent().Length() == 0 || goquery.NodeName(top) == "body" {
+		siblings = top
+	}
+	siblings.Each(func(_ int, sib *goquery.Selection) {
+		keep := sib.IsSelection(top)
+		if !keep {
+			score, ok := scores[sib.Get(0)]
+			if ok && sib.AttrOr("class", "") != "" && sib.AttrOr("class", "") == top.AttrOr("class", "") {
+				score += topScore * 0.2
+			}
+			keep = ok && score >= threshold
+		}
+		if !keep && goquery.NodeName(sib) == "p" {
+			text := normalizeSpace(sib.Text())
+			length, ld := utf8.RuneCountInString(text), linkDensity(sib)
+			keep = (length > 80 && ld < 0.25) || (length > 0 && length <= 80 && ld == 0 && strings.HasSuffix(text, "."))
+		}
+		if keep {
+			if goquery.NodeName(sib) == "body" {
+				content.AppendSelection(sib.Children().Clone())
+			} else {
+				content.AppendSelection(sib.Clone())
+			}
+		}
+	})
+
+	cleanArticle(content, scores)
+	sanitizeArticle(content, base)
+	a.HTML, _ = content.Html()
+	a.HTML = strings.TrimSpace(a.HTML)
+	a.Text = articleText(content.Get(0))
+	return a, nil
+}
+
+// cleanArticle removes lists, tables and divs which look like navigation or ads, and
+// headings which are mostly links.
+func cleanArticle(content *goquery.Selection, scores map[*html.Node]float64) {
+	content.Find("table, ul, ol, div, section").Each(func(_ int, sel *goquery.Selection) {
+		weight := classWeight(sel)
+		if weight+scores[sel.Get(0)] < 0 {
+			sel.Remove()
+			return
+		}
+		text := normalizeSpace(sel.Text())
+		length := utf8.RuneCountInString(text)
+		imgs := sel.Find("img").Length()
+		ld := linkDensity(sel)
+		if (ld > 0.5 && weight < 25) || (ld > 0.2 && weight < 25 && length < 100) || (length < 25 && imgs == 0 && sel.Find("pre, table").Length() == 0) {
+			sel.Remove()
+		}
+	})
+	content.Find("h1, h2, h3").Each(func(_ int, sel *goquery.Selection) {
+		if classWeight(sel) < 0 || linkDensity(sel) > 0.33 {
+			sel.Remove()
+		}
+	})
+	content.Find("p").Each(func(_ int, sel *goquery.Selection) {
+		if normalizeSpace(sel.Text()) == "" && sel.Find("img").Length() == 0 {
+			sel.Remove()
+		}
+	})
+}
+
+// sanitizeArticle keeps only readabilityTags and a few attributes, and makes URLs absolute.
+func sanitizeArticle(content *goquery.Selection, base *url.URL) {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			switch c.Type {
+			case html.ElementNode:
+				walk(c)
+				if !readabilityTags[c.Data] {
+					for gc := c.FirstChild; gc != nil; {
+						gnext := gc.NextSibling
+						c.RemoveChild(gc)
+						n.InsertBefore(gc, c)
+						gc = gnext
+					}
+					n.RemoveChild(c)
+					break
+				}
+				var attrs []html.Attribute
+				for _, attr := range c.Attr {
+					switch attr.Key {
+					case "href", "src":
+						if u := resolveURL(base, attr.Val); u != nil {
+							attrs = append(attrs, html.Attribute{Key: attr.Key, Val: u.String()})
+						}
+					case "alt", "title":
+						attrs = append(attrs, attr)
+					}
+				}
+				c.Attr = attrs
+			case html.CommentNode:
+				n.RemoveChild(c)
+			}
+			c = next
+		}
+	}
+	walk(content.Get(0))
+}
+
+// articleText returns the text of n with paragraphs separated by a blank line. Spaces
+// are collapsed except in <pre>.
+func articleText(n *html.Node) string {
+	var paragraphs []string
+	var cur strings.Builder
+	flush := func() {
+		if t := normalizeSpace(cur.String()); t != "" {
+			paragraphs = append(paragraphs, t)
+		}
+		cur.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case html.TextNode:
+				cur.WriteString(c.Data)
+			case html.ElementNode:
+				if c.Data == "pre" {
+					flush()
+					if t := strings.TrimSpace(goquery.NewDocumentFromNode(c).Text()); t != "" {
+						paragraphs = append(paragraphs, t)
+					}
+					continue
+				}
+				block := readabilityTextBlocks[c.Data]
+				if block {
+					flush()
+				}
+				walk(c)
+				if block {
+					flush()
+				}
+			}
+		}
+	}
+	walk(n)
+	flush()
+	return strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,59 @@
+package goreq
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestResponse_Article runs Article on the saved pages in testdata/readability. Each
+// page.html has a page.json with the expected title and byline, and phrases the text
+// must contain or exclude and the HTML must contain.
+func TestResponse_Article(t *testing.T) {
+	pages, err := filepath.Glob("testdata/readability/*.html")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pages)
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		t.Run(name, func(t *testing.T) {
+			body, err := ioutil.ReadFile(page)
+			assert.NoError(t, err)
+			b, err := ioutil.ReadFile(strings.TrimSuffix(page, ".html") + ".json")
+			assert.NoError(t, err)
+			var expect struct {
+				Title, Byline      string
+				Contains, Excludes []string
+				HTML               []string
+			}
+			assert.NoError(t, json.Unmarshal(b, &expect))
+
+			resp := &Response{Req: Get("http://example.com/" + name), Body: body, Text: string(body)}
+			a, err := resp.Article()
+			assert.NoError(t, err)
+			assert.Equal(t, expect.Title, a.Title)
+			assert.Equal(t, expect.Byline, a.Byline)
+			for _, s := range expect.Contains {
+				assert.Contains(t, a.Text, s)
+			}
+			for _, s := range expect.Excludes {
+				assert.NotContains(t, a.Text, s)
+				assert.NotContains(t, a.HTML, s)
+			}
+			for _, s := range expect.HTML {
+				assert.Contains(t, a.HTML, s)
+			}
+			assert.NotContains(t, a.Text, "\n\n\n")
+			assert.NotContains(t, a.HTML, "class=")
+		})
+	}
+
+	// the document of HTML() is not modified
+	resp := &Response{Req: Get("http://example.com/"), Body: []byte(`<div class="sidebar">side</div><p>text</p>`)}
+	_, err = resp.Article()
+	assert.NoError(t, err)
+	h, _ := resp.HTML()
+	assert.Equal(t, 1, h.Find(".sidebar").Length())
+}
@@ -4,13 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"mime/multipart"
 	"net/http"
-	"net/textproto"
 	"net/url"
 	"strings"
 	"time"
@@ -70,6 +67,9 @@ type Request struct {
 
 	callback func(resp *Response) *Response
 	client   *Client
+	// cancel stops the timeout set by SetTimeout, timeoutParent is the context it's set on.
+	cancel        context.CancelFunc
+	timeoutParent context.Context
 
 	Err error
 }
@@ -84,12 +84,39 @@ func (s *Request) SetDebug(d bool) *Request {
 	return s
 }
 
+// SetRespEncode overrides the encoding of the response body, only a BOM takes precedence.
+func (s *Request) SetRespEncode(e string) *Request {
+	s.RespEncode = e
+	return s
+}
+
+// SetTimeout sets the timeout of the request. Calling it again replaces the timeout, the
+// earlier one is stopped.
 func (s *Request) SetTimeout(t time.Duration) *Request {
-	ctx, _ := context.WithTimeout(s.Context(), t)
+	parent := s.Context()
+	if s.cancel != nil {
+		s.cancel()
+		parent = valuesContext{Context: s.timeoutParent, values: parent}
+	} else {
+		s.timeoutParent = parent
+	}
+	ctx, cancel := context.WithTimeout(parent, t)
 	s.Request = s.WithContext(ctx)
+	s.cancel = cancel
 	return s
 }
 
+// valuesContext is Context with the values of another context, it keeps the values added
+// to a request after its timeout, which is replaced.
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
 type ctxProxyType struct{}
 
 var ctxProxy = &ctxProxyType{}
@@ -106,6 +133,16 @@ func (s *Request) SetProxy(urladdr string) *Request {
 	return s.addContextValue(ctxProxy, u)
 }
 
+type ctxUploadProgressType struct{}
+
+var ctxUploadProgress = &ctxUploadProgressType{}
+
+// SetUploadProgress sets a callback which is called while the request body is sending.
+// total is -1 if the length of body is unknown.
+func (s *Request) SetUploadProgress(fn func(sent, total int64)) *Request {
+	return s.addContextValue(ctxUploadProgress, fn)
+}
+
 type ctxNoCacheType struct{}
 
 var ctxNoCache = &ctxNoCacheType{}
@@ -245,79 +282,6 @@ func (s *Request) SetJsonBody(v interface{}) *Request {
 	return s
 }
 
-type FormField struct {
-	Name, Value string
-}
-
-type FormFile struct {
-	FieldName, FileName, ContentType string
-	File                             io.Reader
-}
-
-var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
-
-func escapeQuotes(s string) string {
-	return quoteEscaper.Replace(s)
-}
-
-func (s *Request) SetMultipartBody(data ...interface{}) *Request {
-	if s.Err != nil {
-		if s.Debug {
-			log.Println("request has error", s.Err)
-		}
-		return s
-	}
-	buff := bytes.NewBuffer([]byte{})
-	wr := multipart.NewWriter(buff)
-	for _, v := range data {
-		switch v.(type) {
-		case FormField:
-			s.Err = wr.WriteField(v.(FormField).Name, v.(FormField).Value)
-			if s.Err != nil {
-				if s.Debug {
-					log.Println("set multipart FormField body error", s.Err)
-				}
-				return s
-			}
-		case FormFile:
-			var w io.Writer
-			h := make(textproto.MIMEHeader)
-			h.Set("Content-Disposition",
-				fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
-					escapeQuotes(v.(FormFile).FieldName), escapeQuotes(v.(FormFile).FieldName)))
-			if v.(FormFile).ContentType != "" {
-				h.Set("Content-Type", v.(FormFile).ContentType)
-			} else {
-				h.Set("Content-Type", "application/octet-stream")
-			}
-			w, s.Err = wr.CreatePart(h)
-			if s.Err != nil {
-				if s.Debug {
-					log.Println("set multipart body FormFile error", s.Err)
-				}
-				return s
-			}
-			_, s.Err = io.Copy(w, v.(FormFile).File)
-			if s.Err != nil {
-				if s.Debug {
-					log.Println("set multipart body FormFile error", s.Err)
-				}
-				return s
-			}
-		}
-	}
-	s.Err = wr.Close()
-	if s.Err != nil {
-		if s.Debug {
-			log.Println("set multipart body FormFile error", s.Err)
-		}
-		return s
-	}
-	s.SetBody(buff)
-	s.Header.Set("Content-Type", wr.FormDataContentType())
-	return s
-}
-
 func (s *Request) SetCallback(fn func(resp *Response) *Response) *Request {
 	s.callback = fn
 	return s
@@ -332,6 +296,22 @@ func (s *Request) Do() *Response {
 	return s.callback(s.client.Do(s))
 }
 
+// clone returns a copy of the request with ctx. The body is reset by GetBody,
+// so it can be sent again.
+func (s *Request) clone(ctx context.Context) (*Request, error) {
+	r := *s
+	r.Request = s.Request.Clone(ctx)
+	r.cancel, r.timeoutParent = nil, nil
+	if s.GetBody != nil && s.Request.Body != nil && s.Request.Body != http.NoBody {
+		b, err := s.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Request.Body = b
+	}
+	return &r, nil
+}
+
 func (s *Request) String() string {
 	return s.URL.String()
 }
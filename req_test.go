@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -173,6 +176,11 @@ func TestRequest_SetTimeout(t *testing.T) {
 	assert.NoError(t, err)
 	err = Get(ts.URL).SetTimeout(1 * time.Second).Do().Error()
 	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// a later timeout replaces the earlier, values set between are kept
+	req := Get(ts.URL).SetTimeout(1 * time.Second).SetDecode(DecodeNone).SetTimeout(10 * time.Second)
+	assert.NoError(t, req.Do().Error())
+	assert.Equal(t, DecodeNone, decodeMode(req))
 }
 
 func TestRequest_SetCheckRedirect(t *testing.T) {
@@ -190,3 +198,51 @@ func TestRequest_SetCheckRedirect(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, 11, i)
 }
+
+func TestRequest_SetMultipartBodyStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		fh := r.MultipartForm.File["file"][0]
+		f, err := fh.Open()
+		assert.NoError(t, err)
+		data, _ := ioutil.ReadAll(f)
+		_, _ = fmt.Fprintf(w, "%d|%s|%s|%s|%s|%s", r.ContentLength, r.FormValue("a"),
+			fh.Filename, fh.Header.Get("X-Part"), fh.Header.Get("Content-Type"), data)
+	}))
+	defer ts.Close()
+
+	var sent, total int64
+	txt, err := Post(ts.URL+"/redirect").SetMultipartBody(
+		FormField{Name: "a", Value: "1"},
+		FormFile{
+			FieldName:   "file",
+			FileName:    "a.txt",
+			ContentType: "text/plain",
+			File:        strings.NewReader("hello world"),
+			Header:      textproto.MIMEHeader{"X-Part": {"part"}},
+		},
+	).SetUploadProgress(func(s, t int64) {
+		sent, total = s, t
+	}).Do().Txt()
+	assert.NoError(t, err)
+	parts := strings.Split(txt, "|")
+	assert.NotEqual(t, "-1", parts[0])
+	assert.Equal(t, []string{"1", "a.txt", "part", "text/plain", "hello world"}, parts[1:])
+	assert.Equal(t, parts[0], fmt.Sprint(total))
+	assert.Equal(t, total, sent)
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("streamed"))
+		_ = pw.Close()
+	}()
+	txt, err = Post(ts.URL).SetMultipartBody(
+		FormFile{FieldName: "file", FileName: "b.bin", File: pr},
+	).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "-1||b.bin||application/octet-stream|streamed", txt)
+}
@@ -1,28 +1,50 @@
 package goreq
 
 import (
-	"bytes"
 	"encoding/json"
-	"encoding/xml"
 	"github.com/PuerkitoBio/goquery"
-	"github.com/saintfish/chardet"
 	"github.com/tidwall/gjson"
-	"golang.org/x/net/html/charset"
 	"gopkg.in/xmlpath.v2"
-	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Response is a object of HTTP response
 type Response struct {
 	*http.Response
-	Body           []byte
+	// Body is the decoded body, converted to UTF-8 if it's text.
+	Body []byte
+	// RawBody is the body as received, after decompression but before decoding.
+	RawBody []byte
+	// Deprecated: NotDecodedBody is the same as RawBody.
 	NotDecodedBody []byte
+	CompressedBody []byte
 	Text           string
+	// Encoding is the canonical name of the charset Body was decoded from, like utf-8 or
+	// gbk, and EncodingSource is where it came from. Both are empty for a binary body.
+	Encoding       string
+	EncodingSource EncodingSource
 	Req            *Request
 	CacheHash      string
 	Err            error
+
+	// lock holds the *sync.Mutex guarding the fields below and Body, see mutex.
+	lock        atomic.Value
+	decoded     bool
+	decodedBody *decodedBody
+	parsedBody  *parsedBody
+}
+
+// mutex returns the lock of response. It's created on first use, so a Response made by
+// a literal needs no setup, and responses never wait for each other.
+func (s *Response) mutex() *sync.Mutex {
+	if m, ok := s.lock.Load().(*sync.Mutex); ok {
+		return m
+	}
+	s.lock.CompareAndSwap(nil, &sync.Mutex{})
+	return s.lock.Load().(*sync.Mutex)
 }
 
 func (s *Response) Resp() (*Response, error) {
@@ -30,90 +52,63 @@ func (s *Response) Resp() (*Response, error) {
 }
 
 func (s *Response) Txt() (string, error) {
-	return s.Text, s.Err
+	err := s.lazyDecode()
+	return s.Text, err
 }
 
 func (s *Response) RespAndTxt() (*Response, string, error) {
-	return s, s.Text, s.Err
+	t, err := s.Txt()
+	return s, t, err
 }
 
+// HTML returns the body parsed as HTML. It's parsed once and the same document is
+// returned by later calls, as are XML() and JSON().
 func (s *Response) HTML() (*goquery.Document, error) {
-	if s.Err != nil {
-		return nil, s.Err
+	if err := s.lazyDecode(); err != nil {
+		return nil, err
 	}
-	return goquery.NewDocumentFromReader(bytes.NewReader(s.Body))
+	return s.parsed().html()
 }
 
 func (s *Response) RespAndHTML() (*Response, *goquery.Document, error) {
-	if s.Err != nil {
-		return s, nil, s.Err
-	}
-	h, err := goquery.NewDocumentFromReader(bytes.NewReader(s.Body))
+	h, err := s.HTML()
 	return s, h, err
 }
 
 func (s *Response) XML() (*xmlpath.Node, error) {
-	if s.Err != nil {
-		return nil, s.Err
+	if err := s.lazyDecode(); err != nil {
+		return nil, err
 	}
-	return xmlpath.Parse(bytes.NewReader(s.Body))
+	return s.parsed().xml()
 }
 
 func (s *Response) RespAndXML() (*Response, *xmlpath.Node, error) {
-	if s.Err != nil {
-		return s, nil, s.Err
-	}
-	x, err := xmlpath.Parse(bytes.NewReader(s.Body))
+	x, err := s.XML()
 	return s, x, err
 }
 
+// JSON returns the parsed body, or NotJSONErr if the body isn't valid JSON.
 func (s *Response) JSON() (gjson.Result, error) {
-	return gjson.Parse(s.Text), s.Err
+	if err := s.lazyDecode(); err != nil {
+		return gjson.Result{}, err
+	}
+	return s.parsed().json(s.Text)
 }
 
 func (s *Response) RespAndJSON() (*Response, gjson.Result, error) {
-	return s, gjson.Parse(s.Text), s.Err
+	j, err := s.JSON()
+	return s, j, err
 }
 
 func (s *Response) Error() error {
 	return s.Err
 }
 
-// DecodeAndParas decodes the body to text and try to parse it to html or json.
+// DecodeAndParse decodes a text body to UTF-8. The encoding is sniffed like a browser does,
+// see sniffEncoding, and reported by Encoding and EncodingSource. RawBody keeps the bytes
+// before decoding. The body is decoded only once, later calls do nothing.
 func (s *Response) DecodeAndParse() error {
-	if s.Err != nil {
-		return s.Err
-	}
-	if len(s.Body) == 0 {
-		return nil
-	}
-	s.NotDecodedBody = s.Body
-	contentType := strings.ToLower(s.Header.Get("Content-Type"))
-	if strings.Contains(contentType, "text/") ||
-		strings.Contains(contentType, "/json") {
-		if !strings.Contains(contentType, "charset") {
-			if s.Req.RespEncode != "" {
-				contentType += "; charset=" + s.Req.RespEncode
-			} else {
-				r, err := chardet.NewTextDetector().DetectBest(s.Body)
-				if err != nil {
-					return err
-				}
-				contentType += "; charset=" + r.Charset
-			}
-		}
-		if strings.Contains(contentType, "utf-8") || strings.Contains(contentType, "utf8") {
-			s.Text = string(s.Body)
-		} else {
-			tmpBody, err := encodeBytes(s.Body, contentType)
-			if err != nil {
-				return err
-			}
-			s.Body = tmpBody
-			s.Text = string(s.Body)
-		}
-	}
-	return nil
+	return s.decode()
 }
 
 func (s *Response) IsHTML() bool {
@@ -127,23 +122,15 @@ func (s *Response) IsJSON() bool {
 }
 
 func (s *Response) BindJSON(i interface{}) error {
-	if s.Err != nil {
-		return s.Err
+	if err := s.lazyDecode(); err != nil {
+		return err
 	}
 	return json.Unmarshal(s.Body, i)
 }
 
 func (s *Response) BindXML(i interface{}) error {
-	if s.Err != nil {
-		return s.Err
-	}
-	return xml.Unmarshal(s.Body, i)
-}
-
-func encodeBytes(b []byte, contentType string) ([]byte, error) {
-	r, err := charset.NewReader(bytes.NewReader(b), contentType)
-	if err != nil {
-		return nil, err
+	if err := s.lazyDecode(); err != nil {
+		return err
 	}
-	return ioutil.ReadAll(r)
+	return s.parsed().xmlDecoder().Decode(i)
 }
@@ -0,0 +1,196 @@
+package goreq
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Session is an identity of crawling: requests of the same session share the cookie
+// jar, the proxy, the User-Agent and the browser profile. A Session is never modified
+// after it's created, SessionManager replaces it when the proxy has to change or
+// WithBrowserProfile picks its profile.
+type Session struct {
+	ID        string
+	Jar       http.CookieJar
+	Proxy     string
+	UserAgent string
+	Profile   *BrowserProfile
+}
+
+type SessionOpinion struct {
+	// ProxyPool binds proxies to sessions. A session is bound to a new proxy when its
+	// proxy is quarantined by the pool.
+	ProxyPool *ProxyPool
+	// Proxies are bound to new sessions in turn if ProxyPool is nil.
+	Proxies []string
+	// UserAgents are picked randomly for new sessions, the UA list of WithRandomUA by default.
+	UserAgents []string
+	// Profiles are picked randomly for new sessions instead of UserAgents if it's not empty.
+	Profiles []*BrowserProfile
+	// NewJar creates cookie jars for new sessions, a CookieJar by default.
+	NewJar func() http.CookieJar
+}
+
+// SessionManager keeps sessions by id. Use it by WithSessions.
+type SessionManager struct {
+	opt      SessionOpinion
+	lock     sync.Mutex
+	sessions map[string]*Session
+	next     int
+	rand     *rand.Rand
+}
+
+func NewSessionManager(opt *SessionOpinion) *SessionManager {
+	m := &SessionManager{
+		sessions: map[string]*Session{},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if opt != nil {
+		m.opt = *opt
+	}
+	if len(m.opt.UserAgents) == 0 {
+		m.opt.UserAgents = uaList
+	}
+	if m.opt.NewJar == nil {
+		m.opt.NewJar = func() http.CookieJar {
+			return NewCookieJar()
+		}
+	}
+	return m
+}
+
+// Get returns the session of id, a new session is created if it doesn't exist.
+func (m *SessionManager) Get(id string) *Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		s = &Session{
+			ID:    id,
+			Jar:   m.opt.NewJar(),
+			Proxy: m.pickProxy(),
+		}
+		if len(m.opt.Profiles) > 0 {
+			s.Profile = m.opt.Profiles[m.rand.Intn(len(m.opt.Profiles))]
+			s.UserAgent = s.Profile.UserAgent()
+		} else {
+			s.UserAgent = m.opt.UserAgents[m.rand.Intn(len(m.opt.UserAgents))]
+		}
+		m.sessions[id] = s
+	} else if p := m.opt.ProxyPool; p != nil && (s.Proxy == "" || p.IsQuarantined(s.Proxy)) {
+		ns := *s
+		ns.Proxy = m.pickProxy()
+		s = &ns
+		m.sessions[id] = s
+	}
+	return s
+}
+
+// bindProfile gives the session of id a profile by pick if it has none, and returns the
+// session before and after. They are nil if the session doesn't exist.
+func (m *SessionManager) bindProfile(id string, pick func() *BrowserProfile) (old, s *Session) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	old = m.sessions[id]
+	if old == nil || old.Profile != nil {
+		return old, old
+	}
+	p := pick()
+	if p == nil {
+		return old, old
+	}
+	ns := *old
+	ns.Profile, ns.UserAgent = p, p.UserAgent()
+	m.sessions[id] = &ns
+	return old, &ns
+}
+
+// Remove drops the session of id, the next request of id starts with a new identity.
+func (m *SessionManager) Remove(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.sessions, id)
+}
+
+// Sessions returns all sessions.
+func (m *SessionManager) Sessions() []*Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		res = append(res, s)
+	}
+	return res
+}
+
+func (m *SessionManager) pickProxy() string {
+	if m.opt.ProxyPool != nil {
+		proxy, _ := m.opt.ProxyPool.Pick()
+		return proxy
+	}
+	if len(m.opt.Proxies) == 0 {
+		return ""
+	}
+	proxy := m.opt.Proxies[m.next%len(m.opt.Proxies)]
+	m.next = (m.next + 1) % len(m.opt.Proxies)
+	return proxy
+}
+
+type ctxSessionType struct{}
+
+var ctxSession = &ctxSessionType{}
+
+// SetSession tags the request with a session id, which takes effect by WithSessions.
+func (s *Request) SetSession(id string) *Request {
+	return s.addContextValue(ctxSession, id)
+}
+
+type ctxSessionProxyType struct{}
+
+var ctxSessionProxy = &ctxSessionProxyType{}
+
+type ctxSessionManagerType struct{}
+
+var ctxSessionManager = &ctxSessionManagerType{}
+
+// WithSessions sends requests tagged by SetSession with the cookie jar, proxy, User-Agent
+// and browser profile of their sessions. A proxy set by SetProxy or headers set on the
+// request are not changed. Sessions without a profile get one from WithBrowserProfile
+// used before WithSessions. Results through proxies of the pool are reported to it, and
+// WithProxyPool used before WithSessions leaves requests with a session proxy alone.
+func WithSessions(m *SessionManager) Middleware {
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			id, ok := req.Context().Value(ctxSession).(string)
+			if !ok {
+				return h(req)
+			}
+			s := m.Get(id)
+			req.SetCookieJar(s.Jar).addContextValue(ctxSessionManager, m)
+			if s.Profile != nil {
+				s.Profile.Apply(req)
+			}
+			if req.Header.Get("User-Agent") == "" {
+				req.SetUA(s.UserAgent)
+			}
+			if hasUserProxy(req) {
+				return h(req)
+			}
+			if s.Proxy == "" {
+				if m.opt.ProxyPool != nil {
+					return &Response{Req: req, Err: NoProxyAvailableErr}
+				}
+				return h(req)
+			}
+			req.SetProxy(s.Proxy).addContextValue(ctxSessionProxy, s.Proxy)
+			start := time.Now()
+			res := h(req)
+			if m.opt.ProxyPool != nil && res != nil {
+				m.opt.ProxyPool.Report(s.Proxy, time.Since(start), m.opt.ProxyPool.check(res))
+			}
+			return res
+		}
+	}
+}
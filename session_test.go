@@ -0,0 +1,101 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sessionProxy acts as a proxy answering "name cookie ua" and setting a cookie on the first visit.
+func sessionProxy(name string) *httptest.Server {
+	i := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("sid")
+		sid := ""
+		if err == nil {
+			sid = c.Value
+		} else {
+			i += 1
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: fmt.Sprint(name, i), Path: "/"})
+		}
+		_, _ = fmt.Fprint(w, name, " ", sid, " ", r.UserAgent())
+	}))
+}
+
+func TestWithSessions(t *testing.T) {
+	a, b := sessionProxy("a"), sessionProxy("b")
+	defer a.Close()
+	defer b.Close()
+	m := NewSessionManager(&SessionOpinion{
+		Proxies:    []string{a.URL, b.URL},
+		UserAgents: []string{"ua"},
+	})
+	c := NewClient(WithSessions(m))
+
+	get := func(id string) string {
+		txt, err := Get("http://example.com/").SetSession(id).SetClient(c).Do().Txt()
+		assert.NoError(t, err)
+		return txt
+	}
+	assert.Equal(t, "a  ua", get("1"))
+	assert.Equal(t, "b  ua", get("2"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "a a1 ua", get("1"))
+		assert.Equal(t, "b b1 ua", get("2"))
+	}
+	assert.Equal(t, "a  ua", get("3"))
+	assert.Equal(t, "a a2 ua", get("3"))
+	assert.Len(t, m.Sessions(), 3)
+
+	txt, err := Get("http://example.com/").SetSession("1").SetProxy(b.URL).SetUA("custom").SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "b a1 custom", txt)
+
+	m.Remove("1")
+	assert.Equal(t, "b  ua", get("1"))
+
+	// requests without session use the jar of client and no proxy
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "direct")
+	}))
+	defer ts.Close()
+	txt, err = Get(ts.URL).SetClient(c).Do().Txt()
+	assert.NoError(t, err)
+	assert.Equal(t, "direct", txt)
+}
+
+func TestWithSessionsProxyPool(t *testing.T) {
+	a := sessionProxy("a")
+	defer a.Close()
+	dead := sessionProxy("dead")
+	dead.Close()
+	pool, err := NewProxyPool(&ProxyPoolOpinion{MaxFailures: 1, QuarantineTime: time.Hour}, dead.URL, a.URL)
+	assert.NoError(t, err)
+	m := NewSessionManager(&SessionOpinion{ProxyPool: pool})
+	c := NewClient(WithProxyPool(pool), WithSessions(m))
+
+	s := m.Get("1")
+	assert.Equal(t, dead.URL, s.Proxy)
+	assert.Error(t, Get("http://example.com/").SetSession("1").SetClient(c).Do().Err)
+	assert.True(t, pool.IsQuarantined(dead.URL))
+
+	// the session is bound to a new proxy, its cookies are kept
+	jar := s.Jar
+	for i := 0; i < 3; i++ {
+		txt, err := Get("http://example.com/").SetSession("1").SetClient(c).Do().Txt()
+		assert.NoError(t, err)
+		assert.Contains(t, txt, "a ")
+	}
+	s = m.Get("1")
+	assert.Equal(t, a.URL, s.Proxy)
+	assert.Equal(t, jar, s.Jar)
+	assert.Equal(t, int64(3), pool.Stats()[1].Requests)
+
+	pool.Remove(a.URL)
+	pool.Remove(dead.URL)
+	m.Remove("1")
+	assert.Equal(t, NoProxyAvailableErr, Get("http://example.com/").SetSession("1").SetClient(c).Do().Err)
+}
@@ -0,0 +1,349 @@
+package goreq
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs requests, usually by setting headers or query parameters.
+type Signer interface {
+	Sign(req *Request) error
+}
+
+// SignerFunc is a function as Signer.
+type SignerFunc func(req *Request) error
+
+func (f SignerFunc) Sign(req *Request) error {
+	return f(req)
+}
+
+// WithSigner signs requests by s before they are sent. The signature should cover what
+// other middlewares set, so use WithSigner before them, making it the innermost one.
+// Retried requests are signed again.
+func WithSigner(s Signer) Middleware {
+	return func(x *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			if err := s.Sign(req); err != nil {
+				return &Response{Req: req, Err: err}
+			}
+			return h(req)
+		}
+	}
+}
+
+var BodyNotReplayableErr = errors.New("body can't be read again to sign, GetBody is nil")
+
+// signingBody returns the body of req by GetBody, without consuming req.Body.
+func signingBody(req *Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, BodyNotReplayableErr
+	}
+	b, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return ioutil.ReadAll(b)
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data string) []byte {
+	m := hmac.New(h, key)
+	_, _ = m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsEscape escapes s as URI encoding of AWS, which keeps only unreserved characters.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || keepSlash && c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts the escaped parameters by name, then by value. Sorting the joined
+// "k=v" would put part1=2 before part=1, since '1' sorts before '='.
+func canonicalQuery(q url.Values) string {
+	var pairs [][2]string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, [2]string{awsEscape(k, false), awsEscape(v, false)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	res := make([]string, len(pairs))
+	for i, p := range pairs {
+		res[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(res, "&")
+}
+
+const (
+	awsV4Algorithm   = "AWS4-HMAC-SHA256"
+	awsTimeFormat    = "20060102T150405Z"
+	awsUnsignedHash  = "UNSIGNED-PAYLOAD"
+	awsEmptySHA256   = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	awsContentSHA256 = "X-Amz-Content-Sha256"
+)
+
+// headers never signed, since they may be changed by proxies or the transport.
+var awsIgnoredHeaders = map[string]bool{
+	"Authorization":   true,
+	"User-Agent":      true,
+	"X-Amzn-Trace-Id": true,
+	"Expect":          true,
+	"Connection":      true,
+	"Accept-Encoding": true,
+}
+
+// AWSV4Signer signs requests by AWS Signature Version 4, for AWS and S3 compatible services.
+type AWSV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+	// UnsignedPayload doesn't hash the body, which is required for bodies without GetBody.
+	UnsignedPayload bool
+
+	now func() time.Time
+}
+
+func (s *AWSV4Signer) time() time.Time {
+	if s.now != nil {
+		return s.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (s *AWSV4Signer) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s *AWSV4Signer) signature(t time.Time, canonical string) string {
+	sts := awsV4Algorithm + "\n" + t.Format(awsTimeFormat) + "\n" + s.scope(t) + "\n" +
+		sha256Hex([]byte(canonical))
+	key := hmacSum(sha256.New, []byte("AWS4"+s.SecretAccessKey), t.Format("20060102"))
+	key = hmacSum(sha256.New, key, s.Region)
+	key = hmacSum(sha256.New, key, s.Service)
+	key = hmacSum(sha256.New, key, "aws4_request")
+	return hex.EncodeToString(hmacSum(sha256.New, key, sts))
+}
+
+func (s *AWSV4Signer) payloadHash(req *Request) (string, error) {
+	if s.UnsignedPayload {
+		return awsUnsignedHash, nil
+	}
+	b, err := signingBody(req)
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(b), nil
+}
+
+func (s *AWSV4Signer) canonicalURI(u *url.URL) string {
+	if s.Service == "s3" {
+		p := u.Path
+		if p == "" {
+			p = "/"
+		}
+		return awsEscape(p, true)
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	return awsEscape(p, true)
+}
+
+// canonicalRequest returns the canonical request and the signed header names of req.
+func (s *AWSV4Signer) canonicalRequest(req *Request, header http.Header, query url.Values, payload string) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, vs := range header {
+		if awsIgnoredHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[strings.ToLower(k)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k + ":" + values[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	return strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(query),
+		b.String(),
+		signed,
+		payload,
+	}, "\n"), signed
+}
+
+// Sign sets the Authorization header of req. For s3 the X-Amz-Content-Sha256 header is set too.
+func (s *AWSV4Signer) Sign(req *Request) error {
+	payload, err := s.payloadHash(req)
+	if err != nil {
+		return err
+	}
+	t := s.time()
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", t.Format(awsTimeFormat))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set(awsContentSHA256, payload)
+	}
+	canonical, signed := s.canonicalRequest(req, req.Header, req.URL.Query(), payload)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsV4Algorithm, s.AccessKeyID, s.scope(t), signed, s.signature(t, canonical)))
+	return nil
+}
+
+// Presign returns the URL of req with the signature in query, which is valid for expires.
+// Only the host header is signed. The payload is unsigned for s3, and empty for others.
+func (s *AWSV4Signer) Presign(req *Request, expires time.Duration) (string, error) {
+	if req.Err != nil {
+		return "", req.Err
+	}
+	t := s.time()
+	q := req.URL.Query()
+	q.Set("X-Amz-Algorithm", awsV4Algorithm)
+	q.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(t))
+	q.Set("X-Amz-Date", t.Format(awsTimeFormat))
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if s.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	payload := awsEmptySHA256
+	if s.Service == "s3" {
+		payload = awsUnsignedHash
+	}
+	canonical, _ := s.canonicalRequest(req, nil, q, payload)
+	u := *req.URL
+	u.RawQuery = canonicalQuery(q) + "&X-Amz-Signature=" + s.signature(t, canonical)
+	return u.String(), nil
+}
+
+// HMACSigner signs requests by HMAC of a canonical string, for APIs with their own
+// signing schemes. The default canonical string is
+//
+//	METHOD\nPATH\nSORTED-QUERY\nname:value of each SignedHeaders\nhex SHA-256 of body
+type HMACSigner struct {
+	Key []byte
+	// Hash is sha256.New by default.
+	Hash func() hash.Hash
+	// Header receives the signature, "X-Signature" by default.
+	Header string
+	// Prefix is put before the signature in Header, like "HMAC-SHA256 ".
+	Prefix string
+	// TimestampHeader is set to the unix time and signed if it's not empty.
+	TimestampHeader string
+	// SignedHeaders are the headers in the default canonical string, in order.
+	SignedHeaders []string
+	// Canonicalize builds the string to sign instead of the default one.
+	Canonicalize func(req *Request, body []byte) (string, error)
+	// Encode encodes the signature, hex.EncodeToString by default.
+	Encode func([]byte) string
+
+	now func() time.Time
+}
+
+func (s *HMACSigner) canonical(req *Request, body []byte) string {
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	p := req.URL.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	b.WriteString(p + "\n")
+	b.WriteString(canonicalQuery(req.URL.Query()) + "\n")
+	headers := s.SignedHeaders
+	if s.TimestampHeader != "" {
+		headers = append([]string{s.TimestampHeader}, headers...)
+	}
+	for _, h := range headers {
+		b.WriteString(strings.ToLower(h) + ":" + strings.TrimSpace(req.Header.Get(h)) + "\n")
+	}
+	b.WriteString(sha256Hex(body))
+	return b.String()
+}
+
+func (s *HMACSigner) Sign(req *Request) error {
+	body, err := signingBody(req)
+	if err != nil {
+		return err
+	}
+	if s.TimestampHeader != "" {
+		now := time.Now()
+		if s.now != nil {
+			now = s.now()
+		}
+		req.Header.Set(s.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	}
+	var str string
+	if s.Canonicalize != nil {
+		if str, err = s.Canonicalize(req, body); err != nil {
+			return err
+		}
+	} else {
+		str = s.canonical(req, body)
+	}
+	h, header, encode := s.Hash, s.Header, s.Encode
+	if h == nil {
+		h = sha256.New
+	}
+	if header == "" {
+		header = "X-Signature"
+	}
+	if encode == nil {
+		encode = hex.EncodeToString
+	}
+	req.Header.Set(header, s.Prefix+encode(hmacSum(h, s.Key, str)))
+	return nil
+}
@@ -0,0 +1,165 @@
+package goreq
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// SizeLimitOpinion limits the size of responses in bytes, zero means no limit.
+type SizeLimitOpinion struct {
+	// MaxBodySize limits the body as received, before content codings are removed. If
+	// net/http asked for gzip by itself, the body it gives is already decompressed.
+	MaxBodySize int64
+	// MaxDecompressedSize limits the body after content codings are removed, it protects
+	// against decompression bombs.
+	MaxDecompressedSize int64
+	// MaxHeaderSize limits the response header, see http.Transport.MaxResponseHeaderBytes.
+	MaxHeaderSize int64
+}
+
+// LimitKind tells which limit of SizeLimitOpinion is exceeded.
+type LimitKind string
+
+const (
+	LimitBody         LimitKind = "body"
+	LimitDecompressed LimitKind = "decompressed body"
+	LimitHeader       LimitKind = "header"
+)
+
+// SizeLimitError is the error of a response exceeding a limit of SizeLimitOpinion. The
+// transfer is aborted and Body keeps the bytes received before that, it's nil for the
+// header and for stream responses, whose prefix has been read by the caller.
+type SizeLimitError struct {
+	Limit LimitKind
+	Max   int64
+	Body  []byte
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("response %s exceeds %d bytes", e.Limit, e.Max)
+}
+
+type ctxSizeLimitType struct{}
+
+var ctxSizeLimit = &ctxSizeLimitType{}
+
+// SetSizeLimit limits the size of the response. Zero fields fall back to the limits of
+// the client set by WithSizeLimit.
+func (s *Request) SetSizeLimit(opt *SizeLimitOpinion) *Request {
+	return s.addContextValue(ctxSizeLimit, opt)
+}
+
+// WithSizeLimit limits the size of responses of the client, including those from
+// WithCache and streams. A 101 Switching Protocols response, like of a WebSocket, isn't
+// limited. Requests can change the limits by SetSizeLimit.
+func WithSizeLimit(opt *SizeLimitOpinion) Middleware {
+	return func(x *Client, h Handler) Handler {
+		if opt != nil {
+			x.sizeLimits = *opt
+		}
+		return h
+	}
+}
+
+// sizeLimit returns the limits for req, limits of request override those of the client.
+func (s *Client) sizeLimit(req *Request) SizeLimitOpinion {
+	lim := s.sizeLimits
+	if r, ok := req.Context().Value(ctxSizeLimit).(*SizeLimitOpinion); ok && r != nil {
+		if r.MaxBodySize != 0 {
+			lim.MaxBodySize = r.MaxBodySize
+		}
+		if r.MaxDecompressedSize != 0 {
+			lim.MaxDecompressedSize = r.MaxDecompressedSize
+		}
+		if r.MaxHeaderSize != 0 {
+			lim.MaxHeaderSize = r.MaxHeaderSize
+		}
+	}
+	return lim
+}
+
+// check tells whether a response received before, like one from WithCache, is within
+// the limits.
+func (s SizeLimitOpinion) check(resp *Response) error {
+	m := resp.mutex()
+	m.Lock()
+	raw := resp.RawBody
+	if !resp.decoded {
+		raw = resp.Body
+	}
+	body := raw
+	if resp.CompressedBody != nil {
+		body = resp.CompressedBody
+	}
+	m.Unlock()
+	if s.MaxHeaderSize > 0 && resp.Response != nil {
+		size := 0
+		for k, vs := range resp.Header {
+			for _, v := range vs {
+				size += len(k) + len(v) + 4
+			}
+		}
+		if int64(size) > s.MaxHeaderSize {
+			return &SizeLimitError{Limit: LimitHeader, Max: s.MaxHeaderSize}
+		}
+	}
+	if s.MaxBodySize > 0 && int64(len(body)) > s.MaxBodySize {
+		return &SizeLimitError{Limit: LimitBody, Max: s.MaxBodySize, Body: body[:s.MaxBodySize]}
+	}
+	if s.MaxDecompressedSize > 0 && int64(len(raw)) > s.MaxDecompressedSize {
+		return &SizeLimitError{Limit: LimitDecompressed, Max: s.MaxDecompressedSize, Body: raw[:s.MaxDecompressedSize]}
+	}
+	return nil
+}
+
+// headerLimitErr converts the error of net/http for a too large header to SizeLimitError.
+func headerLimitErr(err error, max int64) error {
+	if max > 0 && strings.Contains(err.Error(), "server response headers exceeded") {
+		return &SizeLimitError{Limit: LimitHeader, Max: max}
+	}
+	return err
+}
+
+// limitedReader reads at most max bytes from Reader, and returns SizeLimitError if there
+// is more.
+type limitedReader struct {
+	io.Reader
+	limit LimitKind
+	max   int64
+	n     int64
+}
+
+func limitReader(r io.Reader, limit LimitKind, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &limitedReader{Reader: r, limit: limit, max: max}
+}
+
+func (s *limitedReader) Read(p []byte) (int, error) {
+	if s.n > s.max {
+		return 0, &SizeLimitError{Limit: s.limit, Max: s.max}
+	}
+	// one more byte tells whether there is more than max
+	if left := s.max - s.n + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := s.Reader.Read(p)
+	s.n += int64(n)
+	if s.n > s.max {
+		return n - int(s.n-s.max), &SizeLimitError{Limit: s.limit, Max: s.max}
+	}
+	return n, err
+}
+
+// readLimited reads r to the end. If a limit is exceeded, the bytes read before are
+// returned and kept in the SizeLimitError.
+func readLimited(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(r)
+	if e, ok := err.(*SizeLimitError); ok {
+		e.Body = b
+	}
+	return b, err
+}
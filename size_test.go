@@ -0,0 +1,92 @@
+package goreq
+
+import (
+	"bytes"
+	"errors"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sizeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bomb":
+			w.Header().Set("Content-Encoding", EncodingGzip)
+			cw, _ := newCompressWriter(EncodingGzip, w)
+			_, _ = cw.Write(bytes.Repeat([]byte{'0'}, 1<<20))
+			_ = cw.Close()
+		case "/header":
+			w.Header().Set("X-Big", strings.Repeat("a", 8192))
+		default:
+			_, _ = io.WriteString(w, strings.Repeat("0123456789", 100))
+		}
+	}))
+}
+
+func sizeLimitErr(t *testing.T, err error) *SizeLimitError {
+	var e *SizeLimitError
+	if !assert.True(t, errors.As(err, &e), "%v", err) {
+		return &SizeLimitError{}
+	}
+	return e
+}
+
+func TestRequest_SetSizeLimit(t *testing.T) {
+	ts := sizeServer()
+	defer ts.Close()
+
+	resp := Get(ts.URL).SetSizeLimit(&SizeLimitOpinion{MaxBodySize: 1000}).Do()
+	assert.NoError(t, resp.Err)
+	assert.Len(t, resp.Body, 1000)
+
+	e := sizeLimitErr(t, Get(ts.URL).SetSizeLimit(&SizeLimitOpinion{MaxBodySize: 15}).Do().Err)
+	assert.Equal(t, LimitBody, e.Limit)
+	assert.Equal(t, "012345678901234", string(e.Body))
+
+	// a small gzip body expands to 1MB
+	bomb := Get(ts.URL + "/bomb").SetAcceptEncoding(EncodingGzip)
+	e = sizeLimitErr(t, bomb.SetSizeLimit(&SizeLimitOpinion{MaxBodySize: 1 << 20, MaxDecompressedSize: 4096}).Do().Err)
+	assert.Equal(t, LimitDecompressed, e.Limit)
+	assert.Equal(t, bytes.Repeat([]byte{'0'}, 4096), e.Body)
+
+	e = sizeLimitErr(t, Get(ts.URL+"/header").SetSizeLimit(&SizeLimitOpinion{MaxHeaderSize: 1024}).Do().Err)
+	assert.Equal(t, LimitHeader, e.Limit)
+	assert.NoError(t, Get(ts.URL+"/header").Do().Err)
+}
+
+func TestWithSizeLimit(t *testing.T) {
+	ts := sizeServer()
+	defer ts.Close()
+	c := NewClient(WithSizeLimit(&SizeLimitOpinion{MaxBodySize: 100}))
+
+	e := sizeLimitErr(t, Get(ts.URL).SetClient(c).Do().Err)
+	assert.Equal(t, int64(100), e.Max)
+	assert.NoError(t, Get(ts.URL).SetClient(c).SetSizeLimit(&SizeLimitOpinion{MaxBodySize: 1000}).Do().Err)
+
+	// streams are limited while reading
+	resp := Get(ts.URL + "/bomb").SetClient(c).SetAcceptEncoding(EncodingGzip).
+		SetSizeLimit(&SizeLimitOpinion{MaxDecompressedSize: 4096}).Stream().Do()
+	assert.NoError(t, resp.Err)
+	b, err := ioutil.ReadAll(resp.Response.Body)
+	assert.Len(t, b, 4096)
+	assert.Equal(t, LimitDecompressed, sizeLimitErr(t, err).Limit)
+	_ = resp.Response.Body.Close()
+}
+
+func TestWithCacheSizeLimit(t *testing.T) {
+	ts := sizeServer()
+	defer ts.Close()
+	c := NewClient(WithCache(cache.New(time.Minute, time.Minute)))
+
+	assert.NoError(t, Get(ts.URL).SetClient(c).Do().Err)
+	e := sizeLimitErr(t, Get(ts.URL).SetClient(c).SetSizeLimit(&SizeLimitOpinion{MaxBodySize: 10}).Do().Err)
+	assert.Equal(t, "0123456789", string(e.Body))
+	assert.NoError(t, Get(ts.URL).SetClient(c).Do().Err)
+}
@@ -0,0 +1,287 @@
+package goreq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a message of Server-Sent Events.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+var errEventStreamDone = errors.New("event stream is done")
+
+// EventStream reads Server-Sent Events from a Request. Every (re)connection is sent
+// through the Client, so middlewares like auth, proxy and limiters work as usual.
+type EventStream struct {
+	req    *Request
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	maxRetries int
+	once       sync.Once
+	events     chan *Event
+
+	lock        sync.Mutex
+	retry       time.Duration
+	lastEventID string
+	err         error
+}
+
+// EventStream returns an EventStream of the request. Nothing is sent until
+// Events or Listen is called.
+func (s *Request) EventStream() *EventStream {
+	ctx, cancel := context.WithCancel(s.Context())
+	return &EventStream{
+		req:        s,
+		ctx:        ctx,
+		cancel:     cancel,
+		maxRetries: -1,
+		retry:      3 * time.Second,
+	}
+}
+
+// SetRetry sets the reconnection delay, it will be changed by the retry field from server.
+func (s *EventStream) SetRetry(d time.Duration) *EventStream {
+	s.lock.Lock()
+	s.retry = d
+	s.lock.Unlock()
+	return s
+}
+
+// SetMaxRetries sets how many times to reconnect in a row before giving up, -1 means forever.
+func (s *EventStream) SetMaxRetries(n int) *EventStream {
+	s.maxRetries = n
+	return s
+}
+
+// SetLastEventID sets the Last-Event-ID header of the first connection.
+func (s *EventStream) SetLastEventID(id string) *EventStream {
+	s.lock.Lock()
+	s.lastEventID = id
+	s.lock.Unlock()
+	return s
+}
+
+func (s *EventStream) LastEventID() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lastEventID
+}
+
+// Events starts the stream and delivers events on the returned channel.
+// The channel is closed when the stream ends, check Err for the reason.
+func (s *EventStream) Events() <-chan *Event {
+	s.once.Do(func() {
+		s.events = make(chan *Event)
+		go func() {
+			defer close(s.events)
+			err := s.run(func(e *Event) bool {
+				select {
+				case s.events <- e:
+					return true
+				case <-s.ctx.Done():
+					return false
+				}
+			})
+			s.lock.Lock()
+			s.err = err
+			s.lock.Unlock()
+		}()
+	})
+	return s.events
+}
+
+// Listen starts the stream and calls fn for every event. It blocks until the stream ends.
+func (s *EventStream) Listen(fn func(e *Event)) error {
+	return s.run(func(e *Event) bool {
+		if s.ctx.Err() != nil {
+			return false
+		}
+		fn(e)
+		return true
+	})
+}
+
+// Close stops the stream.
+func (s *EventStream) Close() {
+	s.cancel()
+}
+
+// Err returns the error that ended the stream started by Events.
+func (s *EventStream) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+func (s *EventStream) run(emit func(*Event) bool) error {
+	retries := 0
+	for {
+		resp, err := s.connect()
+		if err == nil {
+			retries = 0
+			err = s.read(resp.Response.Body, emit)
+			_ = resp.Response.Body.Close()
+		}
+		if s.ctx.Err() != nil || err == errEventStreamDone {
+			return nil
+		}
+		var fatal *eventStreamError
+		if errors.As(err, &fatal) {
+			return err
+		}
+		if s.maxRetries >= 0 && retries >= s.maxRetries {
+			return err
+		}
+		retries++
+		if s.req.Debug {
+			log.Println("[EventStream] reconnect", s.req.URL, err)
+		}
+		s.lock.Lock()
+		retry := s.retry
+		s.lock.Unlock()
+		select {
+		case <-time.After(retry):
+		case <-s.ctx.Done():
+			return nil
+		}
+	}
+}
+
+type eventStreamError struct {
+	error
+}
+
+func (s *EventStream) connect() (*Response, error) {
+	req, err := s.req.clone(s.ctx)
+	if err != nil {
+		return nil, &eventStreamError{err}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if id := s.LastEventID(); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	} else {
+		req.Header.Del("Last-Event-ID")
+	}
+	req.Stream()
+	resp := req.client.Do(req)
+	if _, ok := resp.Err.(RequestError); ok || resp.Err == ReqRejectedErr {
+		return nil, &eventStreamError{resp.Err}
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		_ = resp.Response.Body.Close()
+		return nil, errEventStreamDone
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Response.Body.Close()
+		return nil, &eventStreamError{fmt.Errorf("event stream got unexpected status %s", resp.Status)}
+	}
+	if t, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); t != "text/event-stream" {
+		_ = resp.Response.Body.Close()
+		return nil, &eventStreamError{fmt.Errorf("event stream got unexpected content type %q", t)}
+	}
+	return resp, nil
+}
+
+func (s *EventStream) read(r io.Reader, emit func(*Event) bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 4096), 1<<20)
+	sc.Split(scanEventLines)
+	var data strings.Builder
+	hasData := false
+	event := ""
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if hasData {
+				e := &Event{
+					ID:    s.LastEventID(),
+					Event: event,
+					Data:  strings.TrimSuffix(data.String(), "\n"),
+				}
+				if e.Event == "" {
+					e.Event = "message"
+				}
+				if !emit(e) {
+					return nil
+				}
+			}
+			data.Reset()
+			hasData = false
+			event = ""
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.SetLastEventID(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.SetRetry(time.Duration(ms) * time.Millisecond)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// scanEventLines splits lines ended by CRLF, LF or CR.
+func scanEventLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
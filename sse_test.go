@@ -0,0 +1,81 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequest_EventStream(t *testing.T) {
+	conn := 0
+	var lastIDs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		conn += 1
+		if conn > 2 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, ": comment\nretry: 10\n\nid: %d\ndata: a\r\ndata: b\r\n\r\n", conn)
+		w.(http.Flusher).Flush()
+		_, _ = fmt.Fprint(w, "event: ping\rdata\r\r")
+	}))
+	defer ts.Close()
+
+	c := NewClient(func(c *Client, h Handler) Handler {
+		return func(req *Request) *Response {
+			req.Header.Set("Authorization", "token")
+			return h(req)
+		}
+	})
+	var events []*Event
+	es := Get(ts.URL).SetClient(c).EventStream()
+	for e := range es.Events() {
+		events = append(events, e)
+	}
+	assert.NoError(t, es.Err())
+	assert.Equal(t, []*Event{
+		{ID: "1", Event: "message", Data: "a\nb"},
+		{ID: "1", Event: "ping", Data: ""},
+		{ID: "2", Event: "message", Data: "a\nb"},
+		{ID: "2", Event: "ping", Data: ""},
+	}, events)
+	assert.Equal(t, []string{"", "1", "2"}, lastIDs)
+}
+
+func TestEventStream_Listen(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	es := Get(ts.URL).EventStream()
+	n := 0
+	err := es.Listen(func(e *Event) {
+		assert.Equal(t, fmt.Sprint(n), e.Data)
+		n += 1
+		if n == 3 {
+			es.Close()
+		}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "not a stream")
+	}))
+	defer ts2.Close()
+	assert.Error(t, Get(ts2.URL).EventStream().Listen(func(e *Event) {}))
+}
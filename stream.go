@@ -0,0 +1,149 @@
+package goreq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+)
+
+type ctxStreamType struct{}
+
+var ctxStream = &ctxStreamType{}
+
+// Stream makes the Client leave the response body unread, the body won't be decoded
+// or cached. Read it from Response.Response.Body and close it when done.
+// Content codings of the body are still removed while reading.
+func (s *Request) Stream() *Request {
+	return s.addContextValue(ctxStream, struct{}{})
+}
+
+func isStream(req *Request) bool {
+	return req.Context().Value(ctxStream) != nil
+}
+
+type streamBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (s *streamBody) Close() error {
+	return s.closer.Close()
+}
+
+// openStream prepares the unread body of a stream response. Reading the body returns
+// SizeLimitError once it exceeds a limit.
+func (s *Response) openStream(lim SizeLimitOpinion) error {
+	// the body of 101 is the connection itself, like a WebSocket, which must stay writable
+	if s.StatusCode == http.StatusSwitchingProtocols {
+		return nil
+	}
+	r, err := decompressReader(s.Response, limitReader(s.Response.Body, LimitBody, lim.MaxBodySize))
+	if err != nil {
+		_ = s.Response.Body.Close()
+		return err
+	}
+	r = limitReader(r, LimitDecompressed, lim.MaxDecompressedSize)
+	if r != io.Reader(s.Response.Body) {
+		s.Response.Body = &streamBody{Reader: r, closer: s.Response.Body}
+	}
+	return nil
+}
+
+// LineIterator reads a streaming response line by line, e.g. NDJSON / JSON lines.
+// Lines are read only when Next is called, so a slow consumer slows down the transfer.
+type LineIterator struct {
+	resp *Response
+	br   *bufio.Reader
+	line []byte
+	err  error
+	done bool
+}
+
+// Lines sends the request in stream mode and returns an iterator over the non-empty lines
+// of the response body. The body is closed when the iteration ends or Close is called.
+// The status code is not checked, see Response.
+func (s *Request) Lines() *LineIterator {
+	resp := s.Stream().client.Do(s)
+	it := &LineIterator{resp: resp, err: resp.Err}
+	if it.err != nil {
+		it.done = true
+		return it
+	}
+	it.br = bufio.NewReader(resp.Response.Body)
+	return it
+}
+
+// Next reads the next line. It returns false when the body ends, an error occurs
+// or the context of request is canceled.
+func (s *LineIterator) Next() bool {
+	for !s.done {
+		if err := s.resp.Req.Context().Err(); err != nil {
+			s.finish(err)
+			return false
+		}
+		line, err := s.br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			s.finish(err)
+			return false
+		}
+		if err == io.EOF {
+			s.finish(nil)
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) > 0 {
+			s.line = line
+			return true
+		}
+	}
+	return false
+}
+
+func (s *LineIterator) finish(err error) {
+	s.done = true
+	s.line = nil
+	if s.err == nil {
+		s.err = err
+	}
+	_ = s.Close()
+}
+
+// Bytes returns the current line without the line break.
+func (s *LineIterator) Bytes() []byte {
+	return s.line
+}
+
+func (s *LineIterator) Text() string {
+	return string(s.line)
+}
+
+// JSON parses the current line as JSON.
+func (s *LineIterator) JSON() gjson.Result {
+	return gjson.ParseBytes(s.line)
+}
+
+// Decode unmarshals the current line into v.
+func (s *LineIterator) Decode(v interface{}) error {
+	return json.Unmarshal(s.line, v)
+}
+
+// Err returns the error stopped the iteration, nil if the body ended normally.
+func (s *LineIterator) Err() error {
+	return s.err
+}
+
+// Response returns the response. Its body is owned by the iterator.
+func (s *LineIterator) Response() *Response {
+	return s.resp
+}
+
+// Close stops the iteration and closes the body.
+func (s *LineIterator) Close() error {
+	s.done = true
+	if s.resp.Response != nil && s.resp.Response.Body != nil {
+		return s.resp.Response.Body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package goreq
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequest_Stream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		_, _ = fmt.Fprint(gw, "streamed")
+		_ = gw.Close()
+	}))
+	defer ts.Close()
+	resp := Get(ts.URL).SetAcceptEncoding(EncodingGzip).Stream().Do()
+	assert.NoError(t, resp.Err)
+	assert.Empty(t, resp.Body)
+	b, err := ioutil.ReadAll(resp.Response.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Response.Body.Close())
+	assert.Equal(t, "streamed", string(b))
+}
+
+func TestRequest_Lines(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprintf(w, "{\"id\":%d,\"name\":\"n%d\"}\r\n\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+		_, _ = fmt.Fprint(w, `{"id":3,"name":"n3"}`)
+	}))
+	defer ts.Close()
+
+	it := Get(ts.URL).Lines()
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.JSON().Get("id").Int())
+		var v struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		}
+		assert.NoError(t, it.Decode(&v))
+		assert.Equal(t, fmt.Sprintf("n%d", v.ID), v.Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []int64{0, 1, 2, 3}, ids)
+}
+
+func TestLineIterator_Cancel(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "%d\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := Get(ts.URL)
+	req.Request = req.WithContext(ctx)
+	it := req.Lines()
+	n := 0
+	for it.Next() {
+		assert.Equal(t, fmt.Sprint(n), it.Text())
+		n += 1
+		if n == 3 {
+			cancel()
+		}
+	}
+	assert.Equal(t, 3, n)
+	assert.True(t, errors.Is(it.Err(), context.Canceled))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("body is not closed")
+	}
+}
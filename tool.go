@@ -11,7 +11,8 @@ import (
 )
 
 func ModifyLink(url string) string {
-	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") ||
+		strings.HasPrefix(url, "wss://") || strings.HasPrefix(url, "ws://") {
 		return url
 	}
 	if strings.HasPrefix(url, ":") {
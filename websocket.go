@@ -0,0 +1,521 @@
+package goreq
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The message types of WebSocket defined in RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// Close codes of WebSocket defined in RFC 6455.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseNoStatusReceived = 1005
+	CloseMessageTooBig    = 1009
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const defaultWebSocketMaxMessageSize = 32 << 20
+
+var (
+	WebSocketBadHandshakeErr = errors.New("bad websocket handshake")
+	WebSocketClosedErr       = errors.New("websocket is closed")
+)
+
+// WebSocketCloseError is returned by ReadMessage when the peer closed the connection.
+type WebSocketCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Text)
+}
+
+type WebSocketOpinion struct {
+	// Protocols is the requested subprotocols.
+	Protocols []string
+	// PingInterval is how often a ping is sent, 0 disables keep-alive.
+	PingInterval time.Duration
+	// PongTimeout closes the connection if nothing is read from the peer within it
+	// after a ping. The default is PingInterval.
+	PongTimeout time.Duration
+	// MaxMessageSize limits the size of messages to read, 32MB by default. A negative value
+	// means no limit.
+	MaxMessageSize int64
+	// CloseTimeout is how long Close waits for the close frame of server before closing
+	// the connection, 5s by default.
+	CloseTimeout time.Duration
+}
+
+// WebSocketConn is a client WebSocket connection. ReadMessage should be called from one
+// goroutine, WriteMessage and Close are safe for concurrent use. Pongs are only noticed
+// while reading, so keep calling ReadMessage if keep-alive is enabled.
+type WebSocketConn struct {
+	// Subprotocol is the subprotocol selected by server.
+	Subprotocol string
+
+	resp *Response
+	rwc  io.ReadWriteCloser
+	br   *bufio.Reader
+	opt  WebSocketOpinion
+
+	// readLock is held by ReadMessage, so Close knows whether it must read the close
+	// frame of server by itself.
+	readLock   sync.Mutex
+	writeLock  sync.Mutex
+	closeOnce  sync.Once
+	closed     chan struct{}
+	sentClose  bool
+	peerOnce   sync.Once
+	peerClosed chan struct{}
+
+	lock     sync.Mutex
+	lastRead time.Time
+}
+
+// WebSocket performs the opening handshake of RFC 6455 through the Client. Headers, cookie jar,
+// proxy, TLS settings and middlewares of the Client are used as a normal request.
+// ws:// and wss:// urls are sent as http:// and https://. The request isn't modified, the
+// handshake is sent by a copy of it.
+func (s *Request) WebSocket(opts ...*WebSocketOpinion) (*WebSocketConn, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	opt := WebSocketOpinion{}
+	if len(opts) > 0 && opts[0] != nil {
+		opt = *opts[0]
+	}
+	if opt.PongTimeout <= 0 {
+		opt.PongTimeout = opt.PingInterval
+	}
+	if opt.CloseTimeout <= 0 {
+		opt.CloseTimeout = 5 * time.Second
+	}
+	if opt.MaxMessageSize == 0 {
+		opt.MaxMessageSize = defaultWebSocketMaxMessageSize
+	}
+
+	req, err := s.clone(s.Context())
+	if err != nil {
+		return nil, err
+	}
+	switch req.URL.Scheme {
+	case "ws":
+		req.URL.Scheme = "http"
+	case "wss":
+		req.URL.Scheme = "https"
+	}
+	key := make([]byte, 16)
+	if _, err = io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	challenge := base64.StdEncoding.EncodeToString(key)
+	req.Method = http.MethodGet
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", challenge)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(opt.Protocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(opt.Protocols, ", "))
+	}
+	req.Stream()
+
+	resp := req.client.Do(req)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	fail := func(format string, a ...interface{}) (*WebSocketConn, error) {
+		_ = resp.Response.Body.Close()
+		return nil, fmt.Errorf("%w: "+format, append([]interface{}{WebSocketBadHandshakeErr}, a...)...)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fail("got status %s", resp.Status)
+	}
+	if !headerContainsToken(resp.Header, "Upgrade", "websocket") ||
+		!headerContainsToken(resp.Header, "Connection", "upgrade") {
+		return fail("missing upgrade headers")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(challenge) {
+		return fail("mismatched Sec-WebSocket-Accept")
+	}
+	rwc, ok := resp.Response.Body.(io.ReadWriteCloser)
+	if !ok {
+		return fail("response body is not writable")
+	}
+	c := &WebSocketConn{
+		Subprotocol: resp.Header.Get("Sec-WebSocket-Protocol"),
+		resp:        resp,
+		rwc:         rwc,
+		br:          bufio.NewReader(rwc),
+		opt:         opt,
+		closed:      make(chan struct{}),
+		peerClosed:  make(chan struct{}),
+		lastRead:    time.Now(),
+	}
+	if c.Subprotocol != "" && !containsString(opt.Protocols, c.Subprotocol) {
+		return fail("unexpected subprotocol %q", c.Subprotocol)
+	}
+	if opt.PingInterval > 0 {
+		go c.keepAlive()
+	}
+	return c, nil
+}
+
+// Response returns the handshake response.
+func (c *WebSocketConn) Response() *Response {
+	return c.resp
+}
+
+// ReadMessage reads the next text or binary message. Pings are answered automatically.
+// A *WebSocketCloseError is returned when the peer closes the connection.
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	for {
+		f, err := readWebSocketFrame(c.br, c.opt.MaxMessageSize)
+		if err != nil {
+			c.readFailed(err)
+			return 0, nil, err
+		}
+		c.lock.Lock()
+		c.lastRead = time.Now()
+		c.lock.Unlock()
+		if f.masked {
+			c.closeWithCode(CloseProtocolError, "masked frame from server")
+			return 0, nil, errors.New("websocket got masked frame from server")
+		}
+		switch f.opcode {
+		case PingMessage:
+			if err = c.writeFrame(PongMessage, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			ce := &WebSocketCloseError{Code: CloseNoStatusReceived}
+			if len(f.payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(f.payload))
+				ce.Text = string(f.payload[2:])
+			}
+			c.peerOnce.Do(func() { close(c.peerClosed) })
+			c.closeWithCode(ce.Code, "")
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+		default:
+			c.closeWithCode(CloseProtocolError, "unexpected opcode")
+			return 0, nil, fmt.Errorf("websocket got unexpected opcode %d", f.opcode)
+		}
+
+		messageType, data = f.opcode, f.payload
+		for !f.fin {
+			limit := int64(0)
+			if c.opt.MaxMessageSize > 0 {
+				if limit = c.opt.MaxMessageSize - int64(len(data)); limit <= 0 {
+					c.readFailed(webSocketMessageTooBigErr)
+					return 0, nil, webSocketMessageTooBigErr
+				}
+			}
+			f, err = readWebSocketFrame(c.br, limit)
+			if err != nil {
+				c.readFailed(err)
+				return 0, nil, err
+			}
+			switch f.opcode {
+			case 0:
+				data = append(data, f.payload...)
+			case PingMessage:
+				if err = c.writeFrame(PongMessage, f.payload); err != nil {
+					return 0, nil, err
+				}
+				f.fin = false
+			case PongMessage:
+				f.fin = false
+			default:
+				c.closeWithCode(CloseProtocolError, "unexpected opcode")
+				return 0, nil, fmt.Errorf("websocket got unexpected opcode %d in fragmented message", f.opcode)
+			}
+		}
+		return messageType, data, nil
+	}
+}
+
+// WriteMessage sends a message of messageType, which is TextMessage, BinaryMessage,
+// PingMessage or PongMessage.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType == CloseMessage {
+		return errors.New("use Close to close websocket")
+	}
+	return c.writeFrame(messageType, data)
+}
+
+func (c *WebSocketConn) WriteText(s string) error {
+	return c.writeFrame(TextMessage, []byte(s))
+}
+
+// Close sends a normal closure and closes the connection.
+func (c *WebSocketConn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+// CloseWithCode sends a close frame with code and reason, waits for the close frame of
+// server at most CloseTimeout as RFC 6455 7.1.1 suggests, then closes the connection.
+func (c *WebSocketConn) CloseWithCode(code int, reason string) error {
+	err := c.sendClose(code, reason)
+	if err == nil {
+		c.waitClose()
+	}
+	c.closeConn()
+	if err == WebSocketClosedErr {
+		return nil
+	}
+	return err
+}
+
+// waitClose waits for the close frame of server. It's read by the ReadMessage in
+// progress, or by waitClose if there is none. The connection is closed on timeout, which
+// stops the read.
+func (c *WebSocketConn) waitClose() {
+	timer := time.AfterFunc(c.opt.CloseTimeout, c.closeConn)
+	defer timer.Stop()
+	if c.readLock.TryLock() {
+		defer c.readLock.Unlock()
+		for {
+			f, err := readWebSocketFrame(c.br, c.opt.MaxMessageSize)
+			if err != nil || f.opcode == CloseMessage {
+				return
+			}
+		}
+	}
+	select {
+	case <-c.peerClosed:
+	case <-c.closed:
+	}
+}
+
+// closeWithCode sends a close frame and closes the connection without waiting, it's used
+// when the server has closed or the connection has failed.
+func (c *WebSocketConn) closeWithCode(code int, reason string) error {
+	err := c.sendClose(code, reason)
+	c.closeConn()
+	return err
+}
+
+func (c *WebSocketConn) sendClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatusReceived {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+	}
+	return c.writeFrame(CloseMessage, payload)
+}
+
+func (c *WebSocketConn) readFailed(err error) {
+	if err == webSocketMessageTooBigErr {
+		_ = c.closeWithCode(CloseMessageTooBig, "")
+		return
+	}
+	c.closeConn()
+}
+
+func (c *WebSocketConn) closeConn() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		_ = c.rwc.Close()
+	})
+}
+
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	select {
+	case <-c.closed:
+		return WebSocketClosedErr
+	default:
+	}
+	if c.sentClose {
+		return WebSocketClosedErr
+	}
+	if opcode == CloseMessage {
+		c.sentClose = true
+	}
+	return writeWebSocketFrame(c.rwc, true, opcode, payload, true)
+}
+
+func (c *WebSocketConn) keepAlive() {
+	t := time.NewTicker(c.opt.PingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case now := <-t.C:
+			c.lock.Lock()
+			last := c.lastRead
+			c.lock.Unlock()
+			if now.Sub(last) > c.opt.PingInterval+c.opt.PongTimeout {
+				c.closeConn()
+				return
+			}
+			if err := c.writeFrame(PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+type webSocketFrame struct {
+	fin     bool
+	opcode  int
+	masked  bool
+	payload []byte
+}
+
+var webSocketMessageTooBigErr = errors.New("websocket message is too big")
+
+// readWebSocketFrame reads a frame and unmasks the payload. limit <= 0 means no limit. The
+// payload is read as it arrives rather than allocated by the length in the header, which
+// the server may lie about.
+func readWebSocketFrame(r io.Reader, limit int64) (*webSocketFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	f := &webSocketFrame{
+		fin:    head[0]&0x80 != 0,
+		opcode: int(head[0] & 0x0f),
+		masked: head[1]&0x80 != 0,
+	}
+	if head[0]&0x70 != 0 {
+		return nil, errors.New("websocket got frame with reserved bits")
+	}
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+		if length < 0 {
+			return nil, webSocketMessageTooBigErr
+		}
+	}
+	if f.opcode >= 8 && (length > 125 || !f.fin) {
+		return nil, errors.New("websocket got invalid control frame")
+	}
+	if limit > 0 && f.opcode < 8 && length > limit {
+		return nil, webSocketMessageTooBigErr
+	}
+	var mask [4]byte
+	if f.masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(r, length))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(payload)) < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	f.payload = payload
+	if f.masked {
+		for i := range f.payload {
+			f.payload[i] ^= mask[i%4]
+		}
+	}
+	return f, nil
+}
+
+func writeWebSocketFrame(w io.Writer, fin bool, opcode int, payload []byte, mask bool) error {
+	buf := make([]byte, 0, 14+len(payload))
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	buf = append(buf, b0)
+	b1 := byte(0)
+	if mask {
+		b1 = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, b1|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, b1|126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, b1|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if mask {
+		var key [4]byte
+		if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		for i, b := range payload {
+			buf = append(buf, b^key[i%4])
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func wsAcceptKey(challenge string) string {
+	h := sha1.New()
+	h.Write([]byte(challenge + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
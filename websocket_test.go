@@ -0,0 +1,271 @@
+package goreq
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func webSocketEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n" +
+			"Set-Cookie: ws=1\r\n")
+		if p := r.Header.Get("Sec-WebSocket-Protocol"); p != "" {
+			_, _ = brw.WriteString("Sec-WebSocket-Protocol: " + strings.Split(p, ",")[0] + "\r\n")
+		}
+		_, _ = brw.WriteString("\r\n")
+		_ = brw.Flush()
+		br := bufio.NewReader(conn)
+		_ = writeWebSocketFrame(conn, true, PingMessage, []byte("hi"), false)
+		for {
+			f, err := readWebSocketFrame(br, 0)
+			if err != nil {
+				return
+			}
+			assert.True(t, f.masked)
+			switch f.opcode {
+			case PingMessage:
+				_ = writeWebSocketFrame(conn, true, PongMessage, f.payload, false)
+				_ = writeWebSocketFrame(conn, true, TextMessage, append([]byte("ping:"), f.payload...), false)
+			case PongMessage:
+				_ = writeWebSocketFrame(conn, true, TextMessage, append([]byte("pong:"), f.payload...), false)
+			case TextMessage, BinaryMessage:
+				if string(f.payload) == "cookie" {
+					c, _ := r.Cookie("session")
+					_ = writeWebSocketFrame(conn, true, TextMessage, []byte(c.Value), false)
+					continue
+				}
+				// echo in two fragments
+				half := len(f.payload) / 2
+				_ = writeWebSocketFrame(conn, false, f.opcode, f.payload[:half], false)
+				_ = writeWebSocketFrame(conn, true, 0, f.payload[half:], false)
+			case CloseMessage:
+				_ = writeWebSocketFrame(conn, true, CloseMessage, f.payload, false)
+				return
+			}
+		}
+	}))
+}
+
+func TestRequest_WebSocket(t *testing.T) {
+	ts := webSocketEchoServer(t)
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	jar := NewCookieJar()
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+	req := Get(strings.Replace(ts.URL, "http://", "ws://", 1)).SetCookieJar(jar)
+	ws, err := req.WebSocket(&WebSocketOpinion{Protocols: []string{"chat"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the handshake is sent by a copy of request
+	assert.Equal(t, "ws", req.URL.Scheme)
+	assert.Equal(t, "", req.Header.Get("Upgrade"))
+	assert.False(t, isStream(req))
+	assert.Equal(t, "chat", ws.Subprotocol)
+	typ, data, err := ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "pong:hi", string(data))
+
+	assert.NoError(t, ws.WriteText("hello websocket"))
+	typ, data, err = ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "hello websocket", string(data))
+
+	big := []byte(strings.Repeat("x", 70000))
+	assert.NoError(t, ws.WriteMessage(BinaryMessage, big))
+	typ, data, err = ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, BinaryMessage, typ)
+	assert.Equal(t, big, data)
+
+	assert.NoError(t, ws.WriteText("cookie"))
+	_, data, err = ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(data))
+
+	// cookies of the handshake response are saved to the jar
+	assert.Contains(t, jar.Cookies(u), &http.Cookie{Name: "ws", Value: "1"})
+
+	assert.NoError(t, ws.Close())
+	assert.Equal(t, WebSocketClosedErr, ws.WriteText("closed"))
+
+	// the cookie jar of client is used without SetCookieJar
+	c := NewClient(WithCookie(ts.URL, &http.Cookie{Name: "session", Value: "client"}))
+	ws, err = Get(ts.URL).SetClient(c).WebSocket()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+	_, _, _ = ws.ReadMessage()
+	assert.NoError(t, ws.WriteText("cookie"))
+	_, data, err = ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "client", string(data))
+}
+
+// webSocketCloseServer answers the close frame after delay, or never if echo is false.
+// closedAt receives when the client closed the connection.
+func webSocketCloseServer(delay time.Duration, echo bool, closedAt chan<- time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		_ = brw.Flush()
+		br := bufio.NewReader(conn)
+		for {
+			f, err := readWebSocketFrame(br, 0)
+			if err != nil {
+				closedAt <- time.Now()
+				return
+			}
+			if f.opcode == CloseMessage && echo {
+				time.Sleep(delay)
+				_ = writeWebSocketFrame(conn, true, CloseMessage, f.payload, false)
+			}
+		}
+	}))
+}
+
+func TestWebSocketConn_Close(t *testing.T) {
+	closedAt := make(chan time.Time, 1)
+	ts := webSocketCloseServer(100*time.Millisecond, true, closedAt)
+	defer ts.Close()
+	ws, err := Get(ts.URL).WebSocket()
+	if !assert.NoError(t, err) {
+		return
+	}
+	start := time.Now()
+	assert.NoError(t, ws.Close())
+	// the connection is kept until the server echoes the close frame
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.True(t, (<-closedAt).Sub(start) >= 100*time.Millisecond)
+
+	// a server which never answers is waited for CloseTimeout
+	ts2 := webSocketCloseServer(0, false, closedAt)
+	defer ts2.Close()
+	ws, err = Get(ts2.URL).WebSocket(&WebSocketOpinion{CloseTimeout: 50 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+	start = time.Now()
+	assert.NoError(t, ws.Close())
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second)
+	<-closedAt
+
+	// the close frame read by a ReadMessage in progress ends the wait
+	ts3 := webSocketCloseServer(50*time.Millisecond, true, closedAt)
+	defer ts3.Close()
+	ws, err = Get(ts3.URL).WebSocket()
+	if !assert.NoError(t, err) {
+		return
+	}
+	done := make(chan error)
+	go func() {
+		_, _, err := ws.ReadMessage()
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	start = time.Now()
+	assert.NoError(t, ws.Close())
+	assert.True(t, time.Since(start) < time.Second)
+	var ce *WebSocketCloseError
+	assert.True(t, errors.As(<-done, &ce))
+	<-closedAt
+}
+
+func TestWebSocketConn_KeepAlive(t *testing.T) {
+	ts := webSocketEchoServer(t)
+	defer ts.Close()
+	ws, err := Get(ts.URL).WebSocket(&WebSocketOpinion{PingInterval: 50 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+	_, data, err := ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "pong:hi", string(data))
+	_, data, err = ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "ping:", string(data))
+}
+
+func TestRequest_WebSocketSizeLimit(t *testing.T) {
+	ts := webSocketEchoServer(t)
+	defer ts.Close()
+	c := NewClient(WithSizeLimit(&SizeLimitOpinion{MaxBodySize: 16, MaxDecompressedSize: 16}))
+	ws, err := Get(ts.URL).SetClient(c).WebSocket()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+	_, data, err := ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "pong:hi", string(data))
+	// messages are limited by MaxMessageSize, not the body limits
+	assert.NoError(t, ws.WriteText(strings.Repeat("x", 100)))
+	_, data, err = ws.ReadMessage()
+	assert.NoError(t, err)
+	assert.Len(t, data, 100)
+}
+
+func TestReadWebSocketFrame_Length(t *testing.T) {
+	// a header claiming 2^62 bytes followed by a short payload
+	frame := []byte{0x82, 127, 0x40, 0, 0, 0, 0, 0, 0, 0, 'a', 'b'}
+	_, err := readWebSocketFrame(bytes.NewReader(frame), 0)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = readWebSocketFrame(bytes.NewReader(frame), 1024)
+	assert.Equal(t, webSocketMessageTooBigErr, err)
+
+	// MaxMessageSize has a default limit
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		_, _ = brw.Write(frame)
+		_ = brw.Flush()
+		_, _ = io.Copy(ioutil.Discard, brw)
+	}))
+	defer ts.Close()
+	ws, err := Get(ts.URL).WebSocket()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+	_, _, err = ws.ReadMessage()
+	assert.Equal(t, webSocketMessageTooBigErr, err)
+}
+
+func TestRequest_WebSocketBadHandshake(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+	_, err := Get(ts.URL).WebSocket()
+	assert.True(t, errors.Is(err, WebSocketBadHandshakeErr))
+}
@@ -0,0 +1,218 @@
+package goreq
+
+import (
+	"errors"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+)
+
+func compileXPath(expr string, namespaces map[string]string) (*xpath.Expr, error) {
+	if len(namespaces) == 0 {
+		return xpath.Compile(expr)
+	}
+	return xpath.CompileWithNS(expr, namespaces)
+}
+
+// xpathValue formats a result of XPath function like count() or string().
+func xpathValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// queryHTML evaluates expr on top. An attribute is returned as an element named by the
+// attribute holding its value as text, and a string, number or boolean result is returned
+// as a text node.
+func queryHTML(top *html.Node, expr *xpath.Expr) []*html.Node {
+	v := expr.Evaluate(htmlquery.CreateXPathNavigator(top))
+	it, ok := v.(*xpath.NodeIterator)
+	if !ok {
+		return []*html.Node{{Type: html.TextNode, Data: xpathValue(v)}}
+	}
+	var nodes []*html.Node
+	for it.MoveNext() {
+		nav := it.Current().(*htmlquery.NodeNavigator)
+		if nav.NodeType() != xpath.AttributeNode {
+			nodes = append(nodes, nav.Current())
+			continue
+		}
+		text := &html.Node{Type: html.TextNode, Data: nav.Value()}
+		nodes = append(nodes, &html.Node{Type: html.ElementNode, Data: nav.LocalName(), FirstChild: text, LastChild: text})
+	}
+	return nodes
+}
+
+// queryXML is queryHTML for XML, attributes are returned as AttributeNode.
+func queryXML(top *xmlquery.Node, expr *xpath.Expr) []*xmlquery.Node {
+	v := expr.Evaluate(xmlquery.CreateXPathNavigator(top))
+	it, ok := v.(*xpath.NodeIterator)
+	if !ok {
+		return []*xmlquery.Node{{Type: xmlquery.TextNode, Data: xpathValue(v)}}
+	}
+	var nodes []*xmlquery.Node
+	for it.MoveNext() {
+		nav := it.Current().(*xmlquery.NodeNavigator)
+		if nav.NodeType() != xpath.AttributeNode {
+			nodes = append(nodes, nav.Current())
+			continue
+		}
+		text := &xmlquery.Node{Type: xmlquery.TextNode, Data: nav.Value()}
+		nodes = append(nodes, &xmlquery.Node{Parent: nav.Current(), Type: xmlquery.AttributeNode, Data: nav.LocalName(), FirstChild: text, LastChild: text})
+	}
+	return nodes
+}
+
+type htmlXPathNode struct {
+	*html.Node
+}
+
+func (n htmlXPathNode) find(sel string) ([]bindNode, error) {
+	expr, err := xpath.Compile(sel)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []bindNode
+	for _, node := range queryHTML(n.Node, expr) {
+		nodes = append(nodes, htmlXPathNode{node})
+	}
+	return nodes, nil
+}
+
+func (n htmlXPathNode) text() string {
+	return htmlquery.InnerText(n.Node)
+}
+
+func (n htmlXPathNode) attr(name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func (n htmlXPathNode) html() (string, error) {
+	return htmlquery.OutputHTML(n.Node, false), nil
+}
+
+type xmlXPathNode struct {
+	node       *xmlquery.Node
+	namespaces map[string]string
+}
+
+func (n xmlXPathNode) find(sel string) ([]bindNode, error) {
+	expr, err := compileXPath(sel, n.namespaces)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []bindNode
+	for _, node := range queryXML(n.node, expr) {
+		nodes = append(nodes, xmlXPathNode{node: node, namespaces: n.namespaces})
+	}
+	return nodes, nil
+}
+
+func (n xmlXPathNode) text() string {
+	return n.node.InnerText()
+}
+
+func (n xmlXPathNode) attr(name string) (string, bool) {
+	for _, a := range n.node.Attr {
+		if a.Name.Local == name || a.Name.Space+":"+a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n xmlXPathNode) html() (string, error) {
+	return n.node.OutputXML(false), nil
+}
+
+// IsXML reports whether the Content-Type is XML, like text/xml or application/atom+xml.
+func (s *Response) IsXML() bool {
+	contentType := strings.ToLower(s.Header.Get("Content-Type"))
+	return strings.Contains(contentType, "/xml") || strings.Contains(contentType, "+xml")
+}
+
+// HTMLNode returns the root of the DOM parsed by HTML().
+func (s *Response) HTMLNode() (*html.Node, error) {
+	h, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Nodes) == 0 {
+		return nil, errors.New("empty html document")
+	}
+	return h.Nodes[0], nil
+}
+
+// XPath evaluates an XPath 1.0 expression on the HTML body. Attributes, like //a/@href,
+// are returned as elements with the value as text, and results of functions, like
+// count(//a), are returned as a text node.
+func (s *Response) XPath(expr string) ([]*html.Node, error) {
+	n, err := s.HTMLNode()
+	if err != nil {
+		return nil, err
+	}
+	e, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return queryHTML(n, e), nil
+}
+
+// XMLNode parses the body as XML by github.com/antchfx/xmlquery.
+func (s *Response) XMLNode() (*xmlquery.Node, error) {
+	if err := s.lazyDecode(); err != nil {
+		return nil, err
+	}
+	return s.parsed().xmlQuery()
+}
+
+// XMLXPath evaluates an XPath 1.0 expression on the XML body. namespaces maps prefixes in
+// expr to namespace URIs, without it prefixes are matched by the prefixes in document.
+func (s *Response) XMLXPath(expr string, namespaces map[string]string) ([]*xmlquery.Node, error) {
+	n, err := s.XMLNode()
+	if err != nil {
+		return nil, err
+	}
+	e, err := compileXPath(expr, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return queryXML(n, e), nil
+}
+
+// BindXPath is BindHTML by XPath expressions, like `goreq:"xpath=//item/title"`. The body
+// is parsed as XML if IsXML, otherwise as HTML. namespaces is used for XML like XMLXPath.
+// Fields of nested structs are evaluated with the matched node as context, so use
+// relative paths like ./title there.
+func (s *Response) BindXPath(i interface{}, namespaces map[string]string) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	b := binder{key: "xpath"}
+	if s.IsXML() {
+		n, err := s.XMLNode()
+		if err != nil {
+			return err
+		}
+		return b.bind(xmlXPathNode{node: n, namespaces: namespaces}, i)
+	}
+	n, err := s.HTMLNode()
+	if err != nil {
+		return err
+	}
+	return b.bind(htmlXPathNode{n}, i)
+}
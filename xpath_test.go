@@ -0,0 +1,157 @@
+package goreq
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"gopkg.in/xmlpath.v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const xpathTestFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+	<title>News</title>
+	<entry>
+		<title>First</title>
+		<link href="https://example.com/1" rel="alternate"/>
+		<updated>2020-01-02T03:04:05Z</updated>
+		<media:thumbnail url="https://example.com/1.jpg"/>
+	</entry>
+	<entry>
+		<title>Second</title>
+		<link href="https://example.com/2" rel="alternate"/>
+		<updated>2020-02-03T04:05:06Z</updated>
+	</entry>
+</feed>`
+
+func xpathTestServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed" {
+			w.Header().Set("Content-Type", "application/atom+xml")
+			_, _ = fmt.Fprint(w, xpathTestFeed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, bindTestHTML)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestResponse_XPath(t *testing.T) {
+	ts := xpathTestServer(t)
+	resp := Get(ts.URL).Do()
+	assert.False(t, resp.IsXML())
+
+	nodes, err := resp.XPath(`//div[@class="item"][2]/h2`)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, "Pear", htmlquery.InnerText(nodes[0]))
+
+	nodes, err = resp.XPath(`//a/@href`)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, "/pear", htmlquery.InnerText(nodes[1]))
+
+	nodes, err = resp.XPath(`count(//li)`)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", htmlquery.InnerText(nodes[0]))
+
+	_, err = resp.XPath(`//div[`)
+	assert.Error(t, err)
+
+	feed := Get(ts.URL + "/feed").Do()
+	assert.True(t, feed.IsXML())
+	entries, err := feed.XMLXPath(`//a:entry[contains(a:title, "Sec")]/a:link/@href`,
+		map[string]string{"a": "http://www.w3.org/2005/Atom"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "https://example.com/2", entries[0].InnerText())
+
+	thumbs, err := feed.XMLXPath(`//media:thumbnail/@url`, nil)
+	assert.NoError(t, err)
+	assert.Len(t, thumbs, 1)
+	assert.Equal(t, "https://example.com/1.jpg", thumbs[0].InnerText())
+
+	// the old xmlpath API still works
+	x, err := feed.XML()
+	assert.NoError(t, err)
+	assert.NotNil(t, x)
+}
+
+func TestResponse_BindXPath(t *testing.T) {
+	ts := xpathTestServer(t)
+
+	var page struct {
+		Names []string `goreq:"xpath=//h2"`
+		Links []string `goreq:"xpath=//a;attr=href"`
+		Count int      `goreq:"xpath=count(//div[@class='item'])"`
+		Items []struct {
+			ID   int    `goreq:"attr=data-id"`
+			Name string `goreq:"xpath=./h2;required"`
+			Desc string `goreq:"xpath=./div;html"`
+		} `goreq:"xpath=//div[@class='item']"`
+	}
+	assert.NoError(t, Get(ts.URL).Do().BindXPath(&page, nil))
+	assert.Equal(t, []string{"Apple", "Pear"}, page.Names)
+	assert.Equal(t, []string{"/apple", "/pear"}, page.Links)
+	assert.Equal(t, 2, page.Count)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, 2, page.Items[1].ID)
+	assert.Equal(t, "Pear", page.Items[1].Name)
+	assert.Equal(t, "<b>red</b> fruit", page.Items[0].Desc)
+
+	var feed struct {
+		Title   string `goreq:"xpath=/a:feed/a:title"`
+		Entries []struct {
+			Title   string    `goreq:"xpath=a:title"`
+			Link    string    `goreq:"xpath=a:link;attr=href"`
+			Updated time.Time `goreq:"xpath=a:updated"`
+			Thumb   string    `goreq:"xpath=media:thumbnail/@url"`
+		} `goreq:"xpath=//a:entry"`
+	}
+	err := Get(ts.URL+"/feed").Do().BindXPath(&feed, map[string]string{
+		"a":     "http://www.w3.org/2005/Atom",
+		"media": "http://search.yahoo.com/mrss/",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "News", feed.Title)
+	assert.Len(t, feed.Entries, 2)
+	assert.Equal(t, "First", feed.Entries[0].Title)
+	assert.Equal(t, "https://example.com/1", feed.Entries[0].Link)
+	assert.Equal(t, time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC), feed.Entries[1].Updated)
+	assert.Equal(t, "https://example.com/1.jpg", feed.Entries[0].Thumb)
+	assert.Equal(t, "", feed.Entries[1].Thumb)
+}
+
+func TestResponse_XMLCharset(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(`<?xml version="1.0" encoding="GBK"?><r><t>中文</t></r>`))
+		_, _ = w.Write(b)
+	}))
+	defer ts.Close()
+
+	// the body decoded from GBK, and the body as received
+	for _, m := range []DecodeMode{DecodeEager, DecodeLazy, DecodeNone} {
+		resp := Get(ts.URL).SetDecode(m).Do()
+		nodes, err := resp.XMLXPath("//t", nil)
+		if assert.NoError(t, err) && assert.Len(t, nodes, 1) {
+			assert.Equal(t, "中文", nodes[0].InnerText())
+		}
+		n, err := resp.XML()
+		if assert.NoError(t, err) {
+			txt, _ := xmlpath.MustCompile("//t").String(n)
+			assert.Equal(t, "中文", txt)
+		}
+		var r struct {
+			T string `xml:"t"`
+		}
+		assert.NoError(t, resp.BindXML(&r))
+		assert.Equal(t, "中文", r.T)
+	}
+}